@@ -0,0 +1,675 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AgentX protocol, see RFC 2741
+const (
+	agentxVersion = 1
+
+	agentxPDUOpen       = 1
+	agentxPDUClose      = 2
+	agentxPDURegister   = 3
+	agentxPDUGet        = 5
+	agentxPDUGetNext    = 6
+	agentxPDUGetBulk    = 7
+	agentxPDUTestSet    = 8
+	agentxPDUCommitSet  = 9
+	agentxPDUUndoSet    = 10
+	agentxPDUCleanupSet = 11
+	agentxPDUResponse   = 18
+
+	agentxFlagNonDefaultContext = 0x08
+	agentxFlagNetworkByteOrder  = 0x10
+
+	agentxTypeInteger             = 2
+	agentxTypeOctetString         = 4
+	agentxTypeGauge32             = 66
+	agentxTypeNoSuchObject        = 128
+	agentxTypeEndOfMibView        = 130
+	agentxErrorNone               = 0
+	agentxErrorNotWritable        = 17
+	agentxErrorUnsupportedContext = 262
+	agentxErrorParse              = 266
+	agentxErrorProcessing         = 268
+
+	agentxHeaderLength = 20
+
+	// reason "shutdown" of the Close PDU
+	agentxCloseShutdown = 5
+)
+
+// sub identifiers of the scalars below piholeSummary (.1)
+var agentxSummaryObjects = map[string]uint32{
+	"domains_being_blocked": 1,
+	"dns_queries_today":     2,
+	"ads_blocked_today":     3,
+	"ads_percentage_today":  4,
+	"unique_domains":        5,
+	"queries_forwarded":     6,
+	"queries_cached":        7,
+	"clients_ever_seen":     8,
+	"unique_clients":        9,
+	"dns_queries_all_types": 10,
+	"privacy_level":         11,
+}
+
+// indices of piholeReplyTable (.2) and piholeQueryTypeTable (.3)
+var agentxReplyIndex = map[string]uint32{
+	"NODATA":   1,
+	"NXDOMAIN": 2,
+	"CNAME":    3,
+	"IP":       4,
+}
+
+var agentxQueryTypeIndex = map[string]uint32{
+	"A":     1,
+	"AAAA":  2,
+	"ANY":   3,
+	"SRV":   4,
+	"SOA":   5,
+	"PTR":   6,
+	"TXT":   7,
+	"NAPTR": 8,
+}
+
+// values served to the master agent, sorted by OID
+var agentxVarBinds []AgentXVarBind
+var agentxLock sync.Mutex
+var agentxStart = time.Now()
+
+// the session is replaced by the subagent and closed on shutdown, the lock also serialises the writes
+var agentxConnectionLock sync.Mutex
+var agentxConnection net.Conn
+var agentxSessionID uint32
+
+func agentxSubagent() {
+	log.WithFields(log.Fields{
+		"agentx_master": config.AgentX.Master,
+		"interval":      config.AgentX.interval.String(),
+	}).Info(formatLogString("Starting AgentX subagent"))
+
+	go agentxUpdater()
+
+	for {
+		err := runAgentXSession()
+		log.WithFields(log.Fields{
+			"agentx_master": config.AgentX.Master,
+			"error":         err.Error(),
+		}).Error(formatLogString("AgentX session terminated, reconnecting"))
+
+		time.Sleep(config.AgentX.interval)
+	}
+}
+
+// agentxUpdater - the master agent expects fast responses, so values are fetched in the background
+func agentxUpdater() {
+	ticker := time.NewTicker(config.AgentX.interval)
+	for {
+		updateAgentXVarBinds()
+		<-ticker.C
+	}
+}
+
+func updateAgentXVarBinds() {
+	// the last values are kept if the PiHole server is not reachable
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	varbinds := buildAgentXVarBinds(rawsum, qtypes)
+
+	agentxLock.Lock()
+	agentxVarBinds = varbinds
+	agentxLock.Unlock()
+}
+
+func buildAgentXVarBinds(rawsum PiHoleRawSummary, qtypes PiHoleQueryTypes) []AgentXVarBind {
+	var result []AgentXVarBind
+
+	oid := func(subids ...uint32) []uint32 {
+		return append(append([]uint32{}, config.AgentX.baseOID...), subids...)
+	}
+
+	for _, v := range getPiHoleValues(rawsum, qtypes) {
+		switch v.Group {
+		case "summary":
+			subid, found := agentxSummaryObjects[v.Name]
+			if !found {
+				continue
+			}
+
+			switch v.Name {
+			case "ads_percentage_today":
+				// percentage in hundredths, SNMP has no floating point type
+				result = append(result, AgentXVarBind{Type: agentxTypeInteger, OID: oid(1, subid, 0), Value: uint64(math.Round(v.Value * 100))})
+			case "privacy_level":
+				result = append(result, AgentXVarBind{Type: agentxTypeInteger, OID: oid(1, subid, 0), Value: uint64(v.Value)})
+			default:
+				result = append(result, AgentXVarBind{Type: agentxTypeGauge32, OID: oid(1, subid, 0), Value: agentxGauge32(v.Value)})
+			}
+		case "reply":
+			index, found := agentxReplyIndex[v.Name]
+			if !found {
+				continue
+			}
+
+			result = append(result, AgentXVarBind{Type: agentxTypeOctetString, OID: oid(2, 1, 2, index), Data: []byte(v.Name)})
+			result = append(result, AgentXVarBind{Type: agentxTypeGauge32, OID: oid(2, 1, 3, index), Value: agentxGauge32(v.Value)})
+		case "querytypes":
+			index, found := agentxQueryTypeIndex[v.Name]
+			if !found {
+				continue
+			}
+
+			result = append(result, AgentXVarBind{Type: agentxTypeOctetString, OID: oid(3, 1, 2, index), Data: []byte(v.Name)})
+			result = append(result, AgentXVarBind{Type: agentxTypeInteger, OID: oid(3, 1, 3, index), Value: uint64(math.Round(v.Value * 100))})
+		}
+	}
+
+	// piholeBlockingStatus: enabled(1), disabled(2)
+	blocking := uint64(2)
+	if rawsum.Status == "enabled" {
+		blocking = 1
+	}
+	result = append(result, AgentXVarBind{Type: agentxTypeInteger, OID: oid(1, 12, 0), Value: blocking})
+	result = append(result, AgentXVarBind{Type: agentxTypeGauge32, OID: oid(1, 13, 0), Value: agentxGauge32(float64(rawsum.GravityLastUpdated.Absolute))})
+
+	sort.Slice(result, func(i int, j int) bool {
+		return compareAgentXOID(result[i].OID, result[j].OID) < 0
+	})
+
+	return result
+}
+
+func agentxGauge32(v float64) uint64 {
+	if v > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	if v < 0 {
+		return 0
+	}
+	return uint64(v)
+}
+
+func runAgentXSession() error {
+	conn, err := net.DialTimeout(config.AgentX.network, config.AgentX.address, config.AgentX.timeout)
+	if err != nil {
+		return err
+	}
+
+	agentxConnectionLock.Lock()
+	agentxConnection = conn
+	agentxConnectionLock.Unlock()
+
+	defer func() {
+		agentxConnectionLock.Lock()
+		agentxConnection = nil
+		agentxSessionID = 0
+		agentxConnectionLock.Unlock()
+
+		conn.Close()
+	}()
+
+	// open session
+	var open = []byte{byte(config.AgentX.Timeout), 0, 0, 0}
+	open = append(open, encodeAgentXOID(config.AgentX.baseOID, false)...)
+	open = append(open, encodeAgentXOctetString([]byte(name+" "+version))...)
+
+	header, payload, err := agentxRequest(conn, agentxPDUOpen, 0, 1, open)
+	if err != nil {
+		return err
+	}
+	err = checkAgentXResponse(header, payload)
+	if err != nil {
+		return fmt.Errorf("Can't open AgentX session: %s", err.Error())
+	}
+	sessionID := header.SessionID
+
+	agentxConnectionLock.Lock()
+	agentxSessionID = sessionID
+	agentxConnectionLock.Unlock()
+
+	// register subtree with default priority
+	var register = []byte{0, 127, 0, 0}
+	register = append(register, encodeAgentXOID(config.AgentX.baseOID, false)...)
+
+	header, payload, err = agentxRequest(conn, agentxPDURegister, sessionID, 2, register)
+	if err != nil {
+		return err
+	}
+	err = checkAgentXResponse(header, payload)
+	if err != nil {
+		return fmt.Errorf("Can't register AgentX subtree: %s", err.Error())
+	}
+
+	log.WithFields(log.Fields{
+		"agentx_master": config.AgentX.Master,
+		"session_id":    sessionID,
+		"subtree":       formatAgentXOID(config.AgentX.baseOID),
+	}).Info(formatLogString("AgentX subtree registered"))
+
+	for {
+		// requests are sent at any time, so there is no read deadline
+		err = conn.SetDeadline(time.Time{})
+		if err != nil {
+			return err
+		}
+
+		header, payload, err := readAgentXPDU(conn)
+		if err != nil {
+			return err
+		}
+
+		switch header.Type {
+		case agentxPDUGet, agentxPDUGetNext, agentxPDUGetBulk:
+			err = handleAgentXRequest(header, payload)
+		case agentxPDUTestSet:
+			err = writeAgentXResponse(header, agentxErrorNotWritable, 1, nil)
+		case agentxPDUCommitSet, agentxPDUUndoSet:
+			err = writeAgentXResponse(header, agentxErrorNone, 0, nil)
+		case agentxPDUCleanupSet, agentxPDUResponse:
+			// no response required
+		case agentxPDUClose:
+			return fmt.Errorf("Session closed by AgentX master")
+		default:
+			err = writeAgentXResponse(header, agentxErrorProcessing, 0, nil)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// closeAgentX - close the session on shutdown, the master removes the registration
+func closeAgentX() {
+	agentxConnectionLock.Lock()
+	sessionID := agentxSessionID
+	agentxConnectionLock.Unlock()
+
+	if sessionID == 0 {
+		return
+	}
+
+	// reason and three reserved bytes, the response is not awaited
+	writeAgentXPDU(AgentXHeader{
+		Version:   agentxVersion,
+		Type:      agentxPDUClose,
+		Flags:     agentxFlagNetworkByteOrder,
+		SessionID: sessionID,
+		PacketID:  3,
+	}, []byte{agentxCloseShutdown, 0, 0, 0})
+}
+
+func handleAgentXRequest(header AgentXHeader, payload []byte) error {
+	var nonRepeaters, maxRepetitions int
+	var result []AgentXVarBind
+	var order = agentxByteOrder(header)
+
+	if header.Flags&agentxFlagNonDefaultContext != 0 {
+		return writeAgentXResponse(header, agentxErrorUnsupportedContext, 0, nil)
+	}
+
+	if header.Type == agentxPDUGetBulk {
+		if len(payload) < 4 {
+			return writeAgentXResponse(header, agentxErrorParse, 0, nil)
+		}
+		nonRepeaters = int(order.Uint16(payload[0:2]))
+		maxRepetitions = int(order.Uint16(payload[2:4]))
+		payload = payload[4:]
+	}
+
+	ranges, err := decodeAgentXSearchRanges(payload, order)
+	if err != nil {
+		return writeAgentXResponse(header, agentxErrorParse, 0, nil)
+	}
+
+	agentxLock.Lock()
+	varbinds := agentxVarBinds
+	agentxLock.Unlock()
+
+	switch header.Type {
+	case agentxPDUGet:
+		for _, r := range ranges {
+			result = append(result, getAgentXVarBind(varbinds, r.Start))
+		}
+	case agentxPDUGetNext:
+		for _, r := range ranges {
+			result = append(result, getNextAgentXVarBind(varbinds, r))
+		}
+	case agentxPDUGetBulk:
+		if nonRepeaters > len(ranges) {
+			nonRepeaters = len(ranges)
+		}
+
+		for _, r := range ranges[:nonRepeaters] {
+			result = append(result, getNextAgentXVarBind(varbinds, r))
+		}
+
+		repeaters := ranges[nonRepeaters:]
+		for i := 0; i < maxRepetitions && len(repeaters) > 0; i++ {
+			var endOfMib = true
+
+			for j := range repeaters {
+				vb := getNextAgentXVarBind(varbinds, repeaters[j])
+				result = append(result, vb)
+
+				if vb.Type != agentxTypeEndOfMibView {
+					endOfMib = false
+					repeaters[j].Start = vb.OID
+					repeaters[j].Include = false
+				}
+			}
+
+			if endOfMib {
+				break
+			}
+		}
+	}
+
+	return writeAgentXResponse(header, agentxErrorNone, 0, result)
+}
+
+func getAgentXVarBind(varbinds []AgentXVarBind, oid []uint32) AgentXVarBind {
+	i := sort.Search(len(varbinds), func(i int) bool {
+		return compareAgentXOID(varbinds[i].OID, oid) >= 0
+	})
+
+	if i < len(varbinds) && compareAgentXOID(varbinds[i].OID, oid) == 0 {
+		return varbinds[i]
+	}
+
+	return AgentXVarBind{Type: agentxTypeNoSuchObject, OID: oid}
+}
+
+func getNextAgentXVarBind(varbinds []AgentXVarBind, r AgentXSearchRange) AgentXVarBind {
+	i := sort.Search(len(varbinds), func(i int) bool {
+		cmp := compareAgentXOID(varbinds[i].OID, r.Start)
+		return cmp > 0 || (cmp == 0 && r.Include)
+	})
+
+	// the end of the range is not included, an empty end means no limit
+	if i < len(varbinds) && (len(r.End) == 0 || compareAgentXOID(varbinds[i].OID, r.End) < 0) {
+		return varbinds[i]
+	}
+
+	return AgentXVarBind{Type: agentxTypeEndOfMibView, OID: r.Start}
+}
+
+func compareAgentXOID(a []uint32, b []uint32) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+func formatAgentXOID(oid []uint32) string {
+	var result string
+
+	for _, subid := range oid {
+		result += fmt.Sprintf(".%d", subid)
+	}
+
+	return result
+}
+
+// agentxRequest - send a PDU to the master agent and wait for the response
+func agentxRequest(conn net.Conn, pduType uint8, sessionID uint32, packetID uint32, payload []byte) (AgentXHeader, []byte, error) {
+	var header = AgentXHeader{
+		Version:   agentxVersion,
+		Type:      pduType,
+		Flags:     agentxFlagNetworkByteOrder,
+		SessionID: sessionID,
+		PacketID:  packetID,
+	}
+
+	err := conn.SetDeadline(time.Now().Add(config.AgentX.timeout))
+	if err != nil {
+		return header, nil, err
+	}
+
+	err = writeAgentXPDU(header, payload)
+	if err != nil {
+		return header, nil, err
+	}
+
+	for {
+		response, payload, err := readAgentXPDU(conn)
+		if err != nil {
+			return response, nil, err
+		}
+
+		if response.Type == agentxPDUResponse && response.PacketID == packetID {
+			return response, payload, nil
+		}
+	}
+}
+
+// checkAgentXResponse - error status of a Response PDU
+func checkAgentXResponse(header AgentXHeader, payload []byte) error {
+	if len(payload) < 8 {
+		return fmt.Errorf("Response PDU is too short")
+	}
+
+	status := agentxByteOrder(header).Uint16(payload[4:6])
+	if status != agentxErrorNone {
+		return fmt.Errorf("AgentX master returned error %d", status)
+	}
+
+	return nil
+}
+
+func writeAgentXResponse(request AgentXHeader, status uint16, index uint16, varbinds []AgentXVarBind) error {
+	var payload = make([]byte, 8)
+
+	// sysUpTime in hundredths of a second
+	binary.BigEndian.PutUint32(payload[0:4], uint32(time.Since(agentxStart)/(10*time.Millisecond)))
+	binary.BigEndian.PutUint16(payload[4:6], status)
+	binary.BigEndian.PutUint16(payload[6:8], index)
+
+	for _, vb := range varbinds {
+		payload = append(payload, encodeAgentXVarBind(vb)...)
+	}
+
+	response := request
+	response.Type = agentxPDUResponse
+	response.Flags = agentxFlagNetworkByteOrder
+
+	return writeAgentXPDU(response, payload)
+}
+
+// writeAgentXPDU - PDUs are always sent in network byte order
+func writeAgentXPDU(header AgentXHeader, payload []byte) error {
+	var pdu = make([]byte, agentxHeaderLength, agentxHeaderLength+len(payload))
+
+	pdu[0] = header.Version
+	pdu[1] = header.Type
+	pdu[2] = header.Flags
+	binary.BigEndian.PutUint32(pdu[4:8], header.SessionID)
+	binary.BigEndian.PutUint32(pdu[8:12], header.TransactionID)
+	binary.BigEndian.PutUint32(pdu[12:16], header.PacketID)
+	binary.BigEndian.PutUint32(pdu[16:20], uint32(len(payload)))
+
+	agentxConnectionLock.Lock()
+	defer agentxConnectionLock.Unlock()
+
+	if agentxConnection == nil {
+		return fmt.Errorf("No connection to the AgentX master")
+	}
+
+	_, err := agentxConnection.Write(append(pdu, payload...))
+	return err
+}
+
+func readAgentXPDU(conn io.Reader) (AgentXHeader, []byte, error) {
+	var header AgentXHeader
+	var raw = make([]byte, agentxHeaderLength)
+
+	_, err := io.ReadFull(conn, raw)
+	if err != nil {
+		return header, nil, err
+	}
+
+	header.Version = raw[0]
+	header.Type = raw[1]
+	header.Flags = raw[2]
+
+	order := agentxByteOrder(header)
+	header.SessionID = order.Uint32(raw[4:8])
+	header.TransactionID = order.Uint32(raw[8:12])
+	header.PacketID = order.Uint32(raw[12:16])
+	header.PayloadLength = order.Uint32(raw[16:20])
+
+	if header.Version != agentxVersion {
+		return header, nil, fmt.Errorf("Unsupported AgentX version %d", header.Version)
+	}
+
+	// the payload is always a multiple of 4
+	if header.PayloadLength%4 != 0 || header.PayloadLength > 1024*1024 {
+		return header, nil, fmt.Errorf("Invalid AgentX payload length %d", header.PayloadLength)
+	}
+
+	payload := make([]byte, header.PayloadLength)
+	_, err = io.ReadFull(conn, payload)
+	if err != nil {
+		return header, nil, err
+	}
+
+	return header, payload, nil
+}
+
+func agentxByteOrder(header AgentXHeader) binary.ByteOrder {
+	if header.Flags&agentxFlagNetworkByteOrder != 0 {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func encodeAgentXOID(oid []uint32, include bool) []byte {
+	var result = make([]byte, 4, 4+4*len(oid))
+
+	// no prefix compression
+	result[0] = byte(len(oid))
+	if include {
+		result[2] = 1
+	}
+
+	for _, subid := range oid {
+		result = append(result, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(result[len(result)-4:], subid)
+	}
+
+	return result
+}
+
+func decodeAgentXOID(data []byte, order binary.ByteOrder) ([]uint32, bool, []byte, error) {
+	var result []uint32
+
+	if len(data) < 4 {
+		return nil, false, nil, fmt.Errorf("OID is too short")
+	}
+
+	count := int(data[0])
+	prefix := data[1]
+	include := data[2] != 0
+	data = data[4:]
+
+	if len(data) < 4*count {
+		return nil, false, nil, fmt.Errorf("OID is too short")
+	}
+
+	// a prefix x is short for 1.3.6.1.x
+	if prefix != 0 {
+		result = append(result, 1, 3, 6, 1, uint32(prefix))
+	}
+
+	for i := 0; i < count; i++ {
+		result = append(result, order.Uint32(data[4*i:4*i+4]))
+	}
+
+	return result, include, data[4*count:], nil
+}
+
+func decodeAgentXSearchRanges(data []byte, order binary.ByteOrder) ([]AgentXSearchRange, error) {
+	var result []AgentXSearchRange
+	var err error
+
+	for len(data) > 0 {
+		var r AgentXSearchRange
+
+		r.Start, r.Include, data, err = decodeAgentXOID(data, order)
+		if err != nil {
+			return nil, err
+		}
+
+		r.End, _, data, err = decodeAgentXOID(data, order)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, r)
+	}
+
+	return result, nil
+}
+
+func encodeAgentXOctetString(data []byte) []byte {
+	var result = make([]byte, 4, 4+len(data)+3)
+
+	binary.BigEndian.PutUint32(result, uint32(len(data)))
+	result = append(result, data...)
+
+	// padding to a multiple of 4
+	for len(result)%4 != 0 {
+		result = append(result, 0)
+	}
+
+	return result
+}
+
+func encodeAgentXVarBind(vb AgentXVarBind) []byte {
+	var result = make([]byte, 4)
+
+	binary.BigEndian.PutUint16(result[0:2], vb.Type)
+	result = append(result, encodeAgentXOID(vb.OID, false)...)
+
+	switch vb.Type {
+	case agentxTypeInteger, agentxTypeGauge32:
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, uint32(vb.Value))
+		result = append(result, value...)
+	case agentxTypeOctetString:
+		result = append(result, encodeAgentXOctetString(vb.Data)...)
+	}
+
+	return result
+}
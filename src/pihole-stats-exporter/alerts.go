@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// e.g. "block_ratio < 0.02 for 30m" or "reply_NXDOMAIN rate > 50/min"
+var alertExprRegexp = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s+(rate\s+)?(<=|>=|==|!=|<|>)\s*([-+]?[0-9]*\.?[0-9]+(?:[eE][-+]?[0-9]+)?)(?:\s*/\s*([a-z]+))?(?:\s+for\s+([0-9]*\.?[0-9]+[smhdw]?))?\s*$`)
+
+var alertRateUnits = map[string]float64{
+	"s":      1,
+	"sec":    1,
+	"second": 1,
+	"m":      60,
+	"min":    60,
+	"minute": 60,
+	"h":      3600,
+	"hour":   3600,
+}
+
+var alertLock sync.Mutex
+var alertStates = make(map[string]*AlertState)
+
+// parseAlertExpression - <metric> [rate] <operator> <threshold>[/<unit>] [for <duration>]
+func parseAlertExpression(cfg *AlertConfiguration) error {
+	match := alertExprRegexp.FindStringSubmatch(cfg.Expr)
+	if match == nil {
+		return fmt.Errorf("Invalid expression %s of alert %s", cfg.Expr, cfg.name)
+	}
+
+	cfg.metric = match[1]
+	if !isPollMetric(cfg.metric) {
+		return fmt.Errorf("Unknown metric %s in expression %s of alert %s", cfg.metric, cfg.Expr, cfg.name)
+	}
+	cfg.rate = match[2] != ""
+	cfg.operator = match[3]
+
+	threshold, err := strconv.ParseFloat(match[4], 64)
+	if err != nil {
+		return fmt.Errorf("Invalid threshold in expression %s of alert %s", cfg.Expr, cfg.name)
+	}
+	cfg.threshold = threshold
+
+	// rates are stored per second, thresholds per minute or hour are converted
+	if match[5] != "" {
+		if !cfg.rate {
+			return fmt.Errorf("Unit of the threshold requires rate in expression %s of alert %s", cfg.Expr, cfg.name)
+		}
+
+		unit, found := alertRateUnits[match[5]]
+		if !found {
+			return fmt.Errorf("Invalid unit %s in expression %s of alert %s", match[5], cfg.Expr, cfg.name)
+		}
+		cfg.threshold /= unit
+	}
+
+	if match[6] != "" {
+		seconds, err := parseCheckDuration(match[6])
+		if err != nil {
+			return fmt.Errorf("Invalid duration in expression %s of alert %s", cfg.Expr, cfg.name)
+		}
+		cfg.forDuration = time.Duration(seconds * float64(time.Second))
+	}
+
+	return nil
+}
+
+func compareAlertValue(value float64, operator string, threshold float64) bool {
+	switch operator {
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
+
+// evaluateAlerts - called after each poll, alerts without data (e.g. the first value of a rate) are treated as inactive
+func evaluateAlerts(doc JSONDocument, now time.Time) {
+	metrics := getPollMetrics(doc)
+
+	alertLock.Lock()
+	for _, rule := range config.Alerts {
+		state, found := alertStates[rule.name]
+		if !found {
+			state = &AlertState{
+				State: "inactive",
+			}
+			alertStates[rule.name] = state
+		}
+
+		value, ok := metrics[rule.metric]
+		if ok && rule.rate {
+			current := value
+			ok = !state.previousTime.IsZero() && current >= state.previousValue && now.After(state.previousTime)
+			if ok {
+				value = (current - state.previousValue) / now.Sub(state.previousTime).Seconds()
+			}
+
+			// counters of the PiHole server are reset at midnight, a decrease starts a new rate
+			state.previousValue = current
+			state.previousTime = now
+		}
+
+		state.HasValue = ok
+		if ok {
+			state.Value = value
+		}
+
+		active := ok && compareAlertValue(value, rule.operator, rule.threshold)
+
+		switch {
+		case active && (state.State == "inactive" || state.State == "resolved"):
+			state.State = "pending"
+			state.ActiveAt = now
+			state.FiredAt = time.Time{}
+			state.ResolvedAt = time.Time{}
+			state.resolvedSent = false
+			renderAlertAnnotations(rule, state)
+
+			if rule.forDuration == 0 {
+				fireAlert(rule, state, now)
+			}
+		case active && state.State == "pending":
+			if now.Sub(state.ActiveAt) >= rule.forDuration {
+				fireAlert(rule, state, now)
+			}
+		case !active && state.State == "pending":
+			state.State = "inactive"
+			state.ActiveAt = time.Time{}
+		case !active && state.State == "firing":
+			state.State = "resolved"
+			state.ResolvedAt = now
+			renderAlertAnnotations(rule, state)
+
+			log.WithFields(log.Fields{
+				"alert":  rule.name,
+				"expr":   rule.Expr,
+				"value":  state.Value,
+				"firing": now.Sub(state.FiredAt).String(),
+			}).Info(formatLogString("Alert resolved"))
+		}
+	}
+	alertLock.Unlock()
+
+	if config.Alertmanager.enabled {
+		pushAlertmanager(now)
+	}
+}
+
+func fireAlert(rule AlertConfiguration, state *AlertState, now time.Time) {
+	state.State = "firing"
+	state.FiredAt = now
+	state.lastSent = time.Time{}
+	renderAlertAnnotations(rule, state)
+
+	log.WithFields(log.Fields{
+		"alert":   rule.name,
+		"expr":    rule.Expr,
+		"value":   state.Value,
+		"pending": now.Sub(state.ActiveAt).String(),
+	}).Warning(formatLogString("Alert is firing"))
+}
+
+func renderAlertAnnotations(rule AlertConfiguration, state *AlertState) {
+	var labels = getAlertLabels(rule)
+
+	state.Annotations = make(map[string]string)
+	for name, tmpl := range rule.annotations {
+		var buffer bytes.Buffer
+
+		err := tmpl.Execute(&buffer, AlertTemplateData{
+			Name:      rule.name,
+			Value:     state.Value,
+			Threshold: rule.threshold,
+			Labels:    labels,
+		})
+		if err != nil {
+			log.WithFields(log.Fields{
+				"alert":      rule.name,
+				"annotation": name,
+				"error":      err.Error(),
+			}).Error(formatLogString("Can't render annotation of alert"))
+
+			continue
+		}
+
+		state.Annotations[name] = buffer.String()
+	}
+}
+
+// getAlertLabels - alertname and instance are always set and can't be replaced by the labels of the rule
+func getAlertLabels(rule AlertConfiguration) map[string]string {
+	var labels = make(map[string]string)
+
+	for name, value := range rule.labels {
+		labels[name] = value
+	}
+
+	labels["alertname"] = rule.name
+	labels["instance"] = config.PiHole.Instance
+
+	return labels
+}
+
+// generateAlertsMetrics - ALERTS metric with the same layout as the one of Prometheus
+func generateAlertsMetrics() []byte {
+	var lines []string
+
+	alertLock.Lock()
+	defer alertLock.Unlock()
+
+	for _, rule := range config.Alerts {
+		state, found := alertStates[rule.name]
+		if !found || (state.State != "pending" && state.State != "firing") {
+			continue
+		}
+
+		labels := getAlertLabels(rule)
+		labels["alertstate"] = state.State
+		labels["upstream"] = config.PiHole.URL
+
+		var names []string
+		for name := range labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var pairs []string
+		for _, name := range names {
+			pairs = append(pairs, name+"="+strconv.Quote(labels[name]))
+		}
+
+		lines = append(lines, "ALERTS{"+strings.Join(pairs, ",")+"} 1\n")
+	}
+
+	return []byte("#HELP ALERTS Alerts evaluated by the exporter which are pending or firing\n#TYPE ALERTS gauge\n" + strings.Join(lines, ""))
+}
+
+func alertsExporter(response http.ResponseWriter, request *http.Request) {
+	log.WithFields(log.Fields{
+		"method":         request.Method,
+		"url":            request.URL.String(),
+		"protocol":       request.Proto,
+		"host":           request.Host,
+		"remote_address": request.RemoteAddr,
+		"headers":        fmt.Sprintf("%+v\n", request.Header),
+		"client_cert":    getClientIdentity(request),
+	}).Info(formatLogString("HTTP request from client received"))
+
+	response.Header().Add("X-Clacks-Overhead", "GNU Terry Pratchett")
+	response.Header().Set("Content-Type", "application/json")
+
+	payload, err := json.Marshal(generateAlertsDocument())
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.Write(payload)
+}
+
+func generateAlertsDocument() JSONAlertList {
+	var result = JSONAlertList{
+		Alerts: make([]JSONAlert, 0),
+	}
+
+	alertLock.Lock()
+	defer alertLock.Unlock()
+
+	for _, rule := range config.Alerts {
+		alert := JSONAlert{
+			Name:        rule.name,
+			State:       "inactive",
+			Expr:        rule.Expr,
+			Labels:      getAlertLabels(rule),
+			Annotations: make(map[string]string),
+		}
+
+		state, found := alertStates[rule.name]
+		if found {
+			alert.State = state.State
+			if state.HasValue {
+				value := state.Value
+				alert.Value = &value
+			}
+			if state.Annotations != nil {
+				alert.Annotations = state.Annotations
+			}
+			alert.ActiveAt = formatAlertTime(state.ActiveAt)
+			alert.FiredAt = formatAlertTime(state.FiredAt)
+			alert.ResolvedAt = formatAlertTime(state.ResolvedAt)
+		}
+
+		result.Alerts = append(result.Alerts, alert)
+	}
+
+	return result
+}
+
+func formatAlertTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// pushAlertmanager - firing alerts are sent again at the interval, resolved alerts are sent once
+func pushAlertmanager(now time.Time) {
+	var alerts []AlertmanagerAlert
+	var sent []*AlertState
+
+	alertLock.Lock()
+	for _, rule := range config.Alerts {
+		state, found := alertStates[rule.name]
+		if !found {
+			continue
+		}
+
+		alert := AlertmanagerAlert{
+			Labels:       getAlertLabels(rule),
+			Annotations:  state.Annotations,
+			StartsAt:     state.FiredAt.UTC().Format(time.RFC3339),
+			GeneratorURL: config.Alertmanager.generatorURL,
+		}
+
+		switch {
+		case state.State == "firing" && now.Sub(state.lastSent) >= config.Alertmanager.interval:
+			// the alert resolves in Alertmanager if it is not sent again in time
+			alert.EndsAt = now.Add(4 * config.Alertmanager.interval).UTC().Format(time.RFC3339)
+		case state.State == "resolved" && !state.resolvedSent:
+			alert.EndsAt = state.ResolvedAt.UTC().Format(time.RFC3339)
+		default:
+			continue
+		}
+
+		alerts = append(alerts, alert)
+		sent = append(sent, state)
+	}
+	alertLock.Unlock()
+
+	if len(alerts) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(alerts)
+	if err != nil {
+		return
+	}
+
+	header := map[string]string{
+		"Content-Type": "application/json",
+	}
+	if config.Alertmanager.Username != "" {
+		header["Authorization"] = basicAuthHeader(config.Alertmanager.Username, config.Alertmanager.Password)
+	}
+
+	result, err := httpRequest(config.Alertmanager.alertsURL, "POST", header, payload, config.Alertmanager.InsecureSSL, config.Alertmanager.CAFile, config.Alertmanager.timeout)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"alertmanager_url": config.Alertmanager.URL,
+			"error":            err.Error(),
+		}).Error(formatLogString("Can't send alerts to Alertmanager"))
+
+		return
+	}
+
+	if result.StatusCode < 200 || result.StatusCode >= 300 {
+		log.WithFields(log.Fields{
+			"alertmanager_url": config.Alertmanager.URL,
+			"status_code":      result.StatusCode,
+			"status":           result.Status,
+			"response":         strings.TrimSpace(string(result.Content)),
+		}).Error(formatLogString("Unexpected HTTP status from Alertmanager"))
+
+		return
+	}
+
+	// alerts which failed are sent again after the next poll
+	alertLock.Lock()
+	for _, state := range sent {
+		if state.State == "resolved" {
+			state.resolvedSent = true
+		} else {
+			state.lastSent = now
+		}
+	}
+	alertLock.Unlock()
+}
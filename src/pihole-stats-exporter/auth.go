@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// a bcrypt comparison takes some time, the results for user name and password are cached
+var authCacheLock sync.Mutex
+var authCache = make(map[[sha256.Size]byte]bool)
+
+// loadAuthUsers - one user per line as user:bcrypt hash, e.g. created by htpasswd -B
+func loadAuthUsers(file string) (map[string][]byte, error) {
+	var users = make(map[string][]byte)
+
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	scanner := bufio.NewScanner(fd)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || entry[0] == '#' {
+			continue
+		}
+
+		colon := strings.Index(entry, ":")
+		if colon <= 0 {
+			return nil, fmt.Errorf("Invalid entry in line %d of %s, expected user:hash", line, file)
+		}
+		user, hash := entry[:colon], []byte(entry[colon+1:])
+
+		_, err = bcrypt.Cost(hash)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid bcrypt hash for user %s in %s: %s", user, file, err.Error())
+		}
+		if users[user] != nil {
+			return nil, fmt.Errorf("Duplicate user %s in %s", user, file)
+		}
+		users[user] = hash
+	}
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	if len(users) == 0 {
+		return nil, fmt.Errorf("No users found in %s", file)
+	}
+	return users, nil
+}
+
+// loadAuthTokens - one token per line, only the SHA256 sum of a token is kept
+func loadAuthTokens(file string) ([][sha256.Size]byte, error) {
+	var tokens [][sha256.Size]byte
+
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		token := strings.TrimSpace(scanner.Text())
+		if token == "" || token[0] == '#' {
+			continue
+		}
+		tokens = append(tokens, sha256.Sum256([]byte(token)))
+	}
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("No tokens found in %s", file)
+	}
+	return tokens, nil
+}
+
+// findAuthPolicy - the policy of the longest matching path applies to the path and all paths below it
+func findAuthPolicy(path string) (map[string]bool, map[string]bool) {
+	var match string
+	methods := config.Auth.methods
+	var users map[string]bool
+
+	for _, policy := range config.AuthPaths {
+		prefix := strings.TrimRight(policy.path, "/")
+		if path != policy.path && path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+		if len(policy.path) > len(match) {
+			match = policy.path
+			methods = policy.methods
+			users = policy.users
+		}
+	}
+
+	return methods, users
+}
+
+// checkBasicAuth - unknown users are compared against a dummy hash so the time doesn't reveal which users exist
+func checkBasicAuth(user string, password string) bool {
+	key := sha256.Sum256([]byte(user + "\x00" + password))
+
+	authCacheLock.Lock()
+	valid, found := authCache[key]
+	authCacheLock.Unlock()
+	if found {
+		return valid
+	}
+
+	hash, known := config.Auth.users[user]
+	if !known {
+		hash = config.Auth.dummyHash
+	}
+	valid = bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil && known
+
+	authCacheLock.Lock()
+	if len(authCache) >= maxAuthCacheEntries {
+		authCache = make(map[[sha256.Size]byte]bool)
+	}
+	authCache[key] = valid
+	authCacheLock.Unlock()
+
+	return valid
+}
+
+// checkBearerToken - all tokens are compared to not reveal the position of a match
+func checkBearerToken(token string) bool {
+	var match int
+
+	sum := sha256.Sum256([]byte(token))
+	for _, t := range config.Auth.tokens {
+		match |= subtle.ConstantTimeCompare(sum[:], t[:])
+	}
+	return match == 1
+}
+
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		methods, users := findAuthPolicy(request.URL.Path)
+		if methods["none"] {
+			// the handlers log all request headers, credentials must not end up in the log
+			request.Header.Del("Authorization")
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		var scheme, credentials string
+		authorization := strings.SplitN(strings.TrimSpace(request.Header.Get("Authorization")), " ", 2)
+		scheme = authorization[0]
+		if len(authorization) == 2 {
+			credentials = strings.TrimSpace(authorization[1])
+		}
+
+		switch {
+		case methods["basic"] && strings.EqualFold(scheme, "Basic"):
+			user, password, ok := request.BasicAuth()
+			if !ok || !checkBasicAuth(user, password) {
+				rejectRequest(response, request, methods, http.StatusUnauthorized, "Invalid user name or password", user)
+				return
+			}
+			if len(users) > 0 && !users[user] {
+				rejectRequest(response, request, methods, http.StatusForbidden, "User is not allowed to access the path", user)
+				return
+			}
+
+		case methods["bearer"] && strings.EqualFold(scheme, "Bearer"):
+			if !checkBearerToken(credentials) {
+				rejectRequest(response, request, methods, http.StatusUnauthorized, "Invalid bearer token", "")
+				return
+			}
+
+		default:
+			rejectRequest(response, request, methods, http.StatusUnauthorized, "", "")
+			return
+		}
+
+		request.Header.Del("Authorization")
+		next.ServeHTTP(response, request)
+	})
+}
+
+// rejectRequest - an empty reason means no (supported) credentials were sent, which is the first request of every browser
+func rejectRequest(response http.ResponseWriter, request *http.Request, methods map[string]bool, status int, reason string, user string) {
+	fields := log.Fields{
+		"method":         request.Method,
+		"url":            request.URL.String(),
+		"remote_address": request.RemoteAddr,
+		"client_cert":    getClientIdentity(request),
+		"status":         status,
+	}
+	if user != "" {
+		fields["user"] = user
+	}
+
+	if reason == "" {
+		log.WithFields(fields).Info(formatLogString("Authentication required, no credentials received"))
+	} else {
+		fields["reason"] = reason
+		log.WithFields(fields).Warning(formatLogString("Authentication failed"))
+	}
+
+	response.Header().Add("X-Clacks-Overhead", "GNU Terry Pratchett")
+
+	if status == http.StatusUnauthorized {
+		if methods["basic"] {
+			response.Header().Add("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s", charset="UTF-8"`, config.Auth.Realm))
+		}
+		// RFC 6750 asks to signal a rejected token
+		if methods["bearer"] {
+			if reason != "" && strings.HasPrefix(strings.ToLower(strings.TrimSpace(request.Header.Get("Authorization"))), "bearer") {
+				response.Header().Add("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s", error="invalid_token"`, config.Auth.Realm))
+			} else {
+				response.Header().Add("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s"`, config.Auth.Realm))
+			}
+		}
+	}
+
+	http.Error(response, http.StatusText(status), status)
+}
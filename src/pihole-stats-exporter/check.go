@@ -0,0 +1,345 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// exit codes of the monitoring plugins
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStatusText = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+var checkDurationUnits = map[byte]float64{
+	's': 1,
+	'm': 60,
+	'h': 3600,
+	'd': 86400,
+	'w': 604800,
+}
+
+func runCheck(args []string) int {
+	var warnings = make(CheckThresholdList)
+	var criticals = make(CheckThresholdList)
+	var err error
+
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	configFile := flags.String("config", "", "Path to configuration file")
+	warnGravityAge := flags.String("warn-gravity-age", "", "Warning if the last gravity update is older")
+	critGravityAge := flags.String("crit-gravity-age", "", "Critical if the last gravity update is older")
+	warnBlockRatio := flags.String("warn-block-ratio", "", "Warning range for the ratio of blocked queries")
+	critBlockRatio := flags.String("crit-block-ratio", "", "Critical range for the ratio of blocked queries")
+	warnBlockingDisabled := flags.Bool("warn-blocking-disabled", false, "Warning if blocking is disabled")
+	critBlockingDisabled := flags.Bool("crit-blocking-disabled", false, "Critical if blocking is disabled")
+	flags.Var(&warnings, "warn", "Warning range for a metric")
+	flags.Var(&criticals, "crit", "Critical range for a metric")
+
+	flags.SetOutput(os.Stdout)
+	flags.Usage = func() {
+		fmt.Printf(checkHelpText, name)
+	}
+
+	err = flags.Parse(args)
+	if err == flag.ErrHelp {
+		return checkOK
+	}
+	if err != nil {
+		return checkUnknown
+	}
+
+	// the plugin output must consist of the status line only
+	log.SetOutput(ioutil.Discard)
+
+	if len(flags.Args()) > 0 {
+		return checkExit(checkUnknown, "Trailing arguments", "")
+	}
+
+	if *configFile == "" {
+		return checkExit(checkUnknown, "Path to configuration file (--config) is mandatory", "")
+	}
+
+	for _, ageRange := range []struct {
+		value      string
+		thresholds CheckThresholdList
+	}{
+		{value: *warnGravityAge, thresholds: warnings},
+		{value: *critGravityAge, thresholds: criticals},
+	} {
+		if ageRange.value == "" {
+			continue
+		}
+
+		age, err := parseCheckDuration(ageRange.value)
+		if err != nil {
+			return checkExit(checkUnknown, err.Error(), "")
+		}
+
+		ageRange.thresholds["gravity_age"] = NagiosRange{Start: 0, End: age, Text: strconv.FormatFloat(age, 'f', -1, 64)}
+	}
+
+	if *warnBlockRatio != "" {
+		err = warnings.Set("block_ratio=" + *warnBlockRatio)
+		if err != nil {
+			return checkExit(checkUnknown, err.Error(), "")
+		}
+	}
+
+	if *critBlockRatio != "" {
+		err = criticals.Set("block_ratio=" + *critBlockRatio)
+		if err != nil {
+			return checkExit(checkUnknown, err.Error(), "")
+		}
+	}
+
+	config, err = parseConfigurationFile(*configFile)
+	if err != nil {
+		return checkExit(checkUnknown, "Can't parse configuration file: "+err.Error(), "")
+	}
+
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return checkExit(checkUnknown, "Can't fetch data from PiHole server: "+err.Error(), "")
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return checkExit(checkUnknown, "Can't fetch data from PiHole server: "+err.Error(), "")
+	}
+
+	values, names, units := getCheckMetrics(rawsum, qtypes, time.Now())
+
+	for _, thresholds := range []CheckThresholdList{warnings, criticals} {
+		for metric := range thresholds {
+			if _, found := values[metric]; !found {
+				return checkExit(checkUnknown, "Unknown or unavailable metric "+metric, "")
+			}
+		}
+	}
+
+	var state = checkOK
+	var problems []string
+	var perfdata []string
+
+	if rawsum.Status != "enabled" {
+		if *critBlockingDisabled {
+			state = checkCritical
+			problems = append(problems, "blocking is "+rawsum.Status)
+		} else if *warnBlockingDisabled {
+			state = checkWarning
+			problems = append(problems, "blocking is "+rawsum.Status)
+		}
+	}
+
+	for _, metric := range names {
+		var value = values[metric]
+		var formatted = strconv.FormatFloat(value, 'f', -1, 64)
+
+		warn, hasWarn := warnings[metric]
+		crit, hasCrit := criticals[metric]
+
+		if hasCrit && crit.alert(value) {
+			state = checkCritical
+			problems = append(problems, fmt.Sprintf("%s is %s%s (critical %s)", metric, formatted, units[metric], crit.Text))
+		} else if hasWarn && warn.alert(value) {
+			if state == checkOK {
+				state = checkWarning
+			}
+			problems = append(problems, fmt.Sprintf("%s is %s%s (warning %s)", metric, formatted, units[metric], warn.Text))
+		}
+
+		perfdata = append(perfdata, fmt.Sprintf("%s=%s%s;%s;%s;;", metric, formatted, units[metric], warn.Text, crit.Text))
+	}
+
+	message := fmt.Sprintf("blocking %s, %d DNS queries today, %.2f%% blocked", rawsum.Status, rawsum.DNSQueriesToday, rawsum.AdsPercentageToday)
+	if age, found := values["gravity_age"]; found {
+		message += fmt.Sprintf(", gravity updated %dd %dh ago", uint64(age)/86400, (uint64(age)%86400)/3600)
+	}
+	if len(problems) > 0 {
+		message = strings.Join(problems, ", ") + "; " + message
+	}
+
+	return checkExit(state, message, strings.Join(perfdata, " "))
+}
+
+// getCheckMetrics - values available for thresholds and performance data
+func getCheckMetrics(rawsum PiHoleRawSummary, qtypes PiHoleQueryTypes, now time.Time) (map[string]float64, []string, map[string]string) {
+	var values = make(map[string]float64)
+	var units = make(map[string]string)
+	var names []string
+
+	add := func(metric string, value float64, unit string) {
+		values[metric] = value
+		units[metric] = unit
+		names = append(names, metric)
+	}
+
+	if rawsum.Status == "enabled" {
+		add("blocking", 1, "")
+	} else {
+		add("blocking", 0, "")
+	}
+
+	add("block_ratio", rawsum.AdsPercentageToday/100.0, "")
+
+	if rawsum.GravityLastUpdated.Absolute > 0 {
+		age := float64(now.Unix()) - float64(rawsum.GravityLastUpdated.Absolute)
+		if age < 0 {
+			age = 0
+		}
+		add("gravity_age", age, "s")
+	}
+
+	for _, v := range getPiHoleValues(rawsum, qtypes) {
+		metric := v.Name
+		if v.Group != "summary" {
+			metric = v.Group + "." + v.Name
+		}
+
+		unit := ""
+		if v.Group == "querytypes" || v.Name == "ads_percentage_today" {
+			unit = "%"
+		}
+
+		add(metric, v.Value, unit)
+	}
+
+	return values, names, units
+}
+
+func checkExit(state int, message string, perfdata string) int {
+	output := "PIHOLE " + checkStatusText[state] + " - " + message
+	if perfdata != "" {
+		output += " | " + perfdata
+	}
+
+	fmt.Println(output)
+	return state
+}
+
+// parseNagiosRange - parse threshold ranges, see https://www.monitoring-plugins.org/doc/guidelines.html#THRESHOLDFORMAT
+func parseNagiosRange(s string) (NagiosRange, error) {
+	var result = NagiosRange{
+		Start: 0,
+		End:   math.Inf(1),
+		Text:  s,
+	}
+	var err error
+
+	if strings.HasPrefix(s, "@") {
+		result.Inside = true
+		s = s[1:]
+	}
+
+	if s == "" {
+		return result, fmt.Errorf("Invalid range %s", result.Text)
+	}
+
+	startEnd := strings.SplitN(s, ":", 2)
+	if len(startEnd) == 1 {
+		result.End, err = strconv.ParseFloat(startEnd[0], 64)
+		if err != nil {
+			return result, fmt.Errorf("Invalid range %s", result.Text)
+		}
+	} else {
+		switch startEnd[0] {
+		case "~":
+			result.Start = math.Inf(-1)
+		case "":
+		default:
+			result.Start, err = strconv.ParseFloat(startEnd[0], 64)
+			if err != nil {
+				return result, fmt.Errorf("Invalid range %s", result.Text)
+			}
+		}
+
+		if startEnd[1] != "" {
+			result.End, err = strconv.ParseFloat(startEnd[1], 64)
+			if err != nil {
+				return result, fmt.Errorf("Invalid range %s", result.Text)
+			}
+		}
+	}
+
+	if result.Start > result.End {
+		return result, fmt.Errorf("Invalid range %s, start is greater than end", result.Text)
+	}
+
+	return result, nil
+}
+
+// alert - values outside of the range raise an alert, or inside of the range if the range starts with @
+func (r NagiosRange) alert(value float64) bool {
+	outside := value < r.Start || value > r.End
+	if r.Inside {
+		return !outside
+	}
+	return outside
+}
+
+// parseCheckDuration - convert a duration like 8d or 12h into seconds
+func parseCheckDuration(s string) (float64, error) {
+	var factor float64 = 1
+
+	if s == "" {
+		return 0, fmt.Errorf("Invalid duration")
+	}
+
+	unit, found := checkDurationUnits[s[len(s)-1]]
+	if found {
+		factor = unit
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("Invalid duration %s", s)
+	}
+
+	return value * factor, nil
+}
+
+// String - implements flag.Value
+func (l *CheckThresholdList) String() string {
+	var result []string
+
+	if l == nil {
+		return ""
+	}
+
+	for metric, r := range *l {
+		result = append(result, metric+"="+r.Text)
+	}
+	sort.Strings(result)
+
+	return strings.Join(result, ",")
+}
+
+// Set - implements flag.Value, values are metric=range
+func (l *CheckThresholdList) Set(s string) error {
+	metricRange := strings.SplitN(s, "=", 2)
+	if len(metricRange) != 2 || metricRange[0] == "" {
+		return fmt.Errorf("Invalid threshold %s, must be <metric>=<range>", s)
+	}
+
+	r, err := parseNagiosRange(metricRange[1])
+	if err != nil {
+		return err
+	}
+
+	(*l)[metricRange[0]] = r
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runCollectd - write PUTVAL commands to stdout for the exec plugin of collectd
+func runCollectd() {
+	host, interval := getCollectdSettings()
+
+	// stdout is reserved for the data
+	log.SetOutput(os.Stderr)
+
+	log.WithFields(log.Fields{
+		"host":     host,
+		"interval": interval.String(),
+	}).Info(formatLogString("Starting collectd exec mode"))
+
+	ticker := time.NewTicker(interval)
+	for {
+		writeCollectd(host, interval)
+		<-ticker.C
+	}
+}
+
+// getCollectdSettings - host name and interval from the configuration or from the environment set by collectd
+func getCollectdSettings() (string, time.Duration) {
+	host := config.Collectd.Host
+	if host == "" {
+		host = os.Getenv("COLLECTD_HOSTNAME")
+	}
+	if host == "" {
+		host = config.PiHole.Instance
+	}
+
+	interval := time.Duration(config.Collectd.Interval) * time.Second
+	if interval == 0 {
+		env, err := strconv.ParseFloat(os.Getenv("COLLECTD_INTERVAL"), 64)
+		if err == nil && env > 0 {
+			interval = time.Duration(env * float64(time.Second))
+		} else {
+			interval = defaultCollectdInterval * time.Second
+		}
+	}
+
+	return host, interval
+}
+
+func writeCollectd(host string, interval time.Duration) {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	_, err = os.Stdout.Write(generateCollectdData(host, interval, rawsum, qtypes, time.Now().Unix()))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error(formatLogString("Can't write data to stdout"))
+	}
+}
+
+func generateCollectdData(host string, interval time.Duration, rawsum PiHoleRawSummary, qtypes PiHoleQueryTypes, now int64) []byte {
+	var result []byte
+
+	// identifier is host/plugin-plugin_instance/type-type_instance, slashes are not allowed in the parts
+	prefix := sanitizeCollectdName(host) + "/pihole-" + sanitizeCollectdName(config.PiHole.Instance) + "/"
+	seconds := strconv.FormatFloat(interval.Seconds(), 'f', -1, 64)
+
+	putval := func(dsType string, typeInstance string, value float64) {
+		result = append(result, []byte(fmt.Sprintf("PUTVAL \"%s%s-%s\" interval=%s %d:%s\n", prefix, dsType, sanitizeCollectdName(typeInstance), seconds, now, strconv.FormatFloat(value, 'f', -1, 64)))...)
+	}
+
+	blocking := 0.0
+	if rawsum.Status == "enabled" {
+		blocking = 1
+	}
+	putval("gauge", "blocking", blocking)
+
+	// types are defined in types.db of collectd
+	for _, v := range getPiHoleValues(rawsum, qtypes) {
+		switch {
+		case v.Group == "querytypes":
+			putval("percent", "querytype_"+v.Name, v.Value)
+		case v.Group == "reply":
+			putval("count", "reply_"+v.Name, v.Value)
+		case v.Name == "ads_percentage_today":
+			putval("percent", v.Name, v.Value)
+		case v.Name == "privacy_level":
+			putval("gauge", v.Name, v.Value)
+		default:
+			putval("count", v.Name, v.Value)
+		}
+	}
+
+	return result
+}
+
+func sanitizeCollectdName(s string) string {
+	return strings.NewReplacer("/", "_", "\"", "_", " ", "_").Replace(s)
+}
@@ -8,6 +8,125 @@ var userAgent = name + "/" + version
 const defaultExporterURL = "http://127.0.0.1:64711"
 const defaultPrometheusPath = "/metrics"
 const defaultInfluxDataPath = "/influx"
+const defaultSSEHeartbeat = 15
+const defaultSSEMaxSubscribers = 16
+const defaultPollInterval = 15
+
+const defaultInfluxDBVersion = 1
+const defaultInfluxDBInterval = 60
+const defaultInfluxDBBatchSize = 5000
+const defaultInfluxDBRetries = 3
+const defaultInfluxDBTimeout = 15
+const defaultInfluxDBSpoolMaxFiles = 1440
+
+const defaultRemoteWriteInterval = 15
+const defaultRemoteWriteTimeout = 30
+const defaultRemoteWriteQueueSize = 100
+const defaultRemoteWriteMinBackoff = 1
+const defaultRemoteWriteMaxBackoff = 60
+
+const defaultPushgatewayJob = "pihole"
+const defaultPushgatewayInterval = 60
+const defaultPushgatewayTimeout = 15
+
+const defaultGraphiteProtocol = "tcp"
+const defaultGraphitePathTemplate = "pihole.{instance}.{metric}"
+const defaultGraphiteInterval = 60
+const defaultGraphiteTimeout = 15
+
+const defaultStatsDProtocol = "udp"
+const defaultStatsDMTU = 1432
+const defaultStatsDInterval = 10
+
+const defaultOTLPInterval = 60
+const defaultOTLPTimeout = 15
+
+const defaultMQTTQoS = 0
+const defaultMQTTInterval = 60
+const defaultMQTTTimeout = 15
+const defaultMQTTDiscoveryPrefix = "homeassistant"
+
+const defaultZabbixPort = "10051"
+const defaultZabbixKeyPrefix = "pihole"
+const defaultZabbixInterval = 60
+const defaultZabbixTimeout = 15
+
+const defaultTextfileInterval = 60
+
+const defaultAgentXInterval = 30
+
+// the playpen of net-snmp (netSnmpPlaypen of NET-SNMP-MIB) is meant for experiments, sites should use their own enterprise number
+const defaultAgentXBaseOID = ".1.3.6.1.4.1.8072.9999.9999.4711"
+
+const defaultCollectdInterval = 60
+
+const defaultQueryLogBatchSize = 1000
+
+const defaultLokiEvents = "blocked"
+const defaultLokiInterval = 30
+const defaultLokiTimeout = 15
+const defaultLokiRetries = 3
+
+const defaultSyslogProtocol = "udp"
+const defaultSyslogFraming = "octet_counting"
+const defaultSyslogFormat = "rfc5424"
+const defaultSyslogFacility = "local0"
+const defaultSyslogEvents = "blocked"
+const defaultSyslogInterval = 10
+const defaultSyslogTimeout = 15
+const defaultSyslogPort = "514"
+const defaultSyslogTLSPort = "6514"
+
+const defaultElasticsearchStatsIndex = "pihole-stats"
+const defaultElasticsearchQueriesIndex = "pihole-queries"
+const defaultElasticsearchIndexRotation = "daily"
+const defaultElasticsearchEvents = "blocked"
+const defaultElasticsearchInterval = 60
+const defaultElasticsearchTimeout = 15
+const defaultElasticsearchRetries = 3
+const defaultElasticsearchMaxBackoff = 60
+
+const defaultEventsInterval = 60
+const defaultEventsGravityMaxAge = 7
+
+const defaultWebhookTemplate = `{"text":{{ json .Message }}}`
+const defaultWebhookContentType = "application/json"
+const defaultWebhookTimeout = 15
+const defaultWebhookRetries = 3
+
+const defaultAlertsPath = "/alerts"
+const defaultHistoryPath = "/api/history"
+const defaultAlertmanagerInterval = 60
+const defaultAlertmanagerTimeout = 15
+
+const defaultHistoryRetention = 30
+const defaultHistoryResolution = 60
+const defaultHistorySaveInterval = 300
+
+// upper limit for the number of slots (e.g. 1 year at a resolution of 30 seconds), for the points and the metrics of a query
+const maxHistorySlots = 1051200
+const maxHistoryPoints = 11000
+const maxHistoryMetrics = 64
+
+// 9999-12-31T23:59:59Z, the last time representable by RFC 3339
+const maxHistoryTime = 253402300799
+
+const defaultReportPeriod = "daily"
+const defaultReportDailySchedule = "0 7 * * *"
+const defaultReportWeeklySchedule = "0 7 * * 1"
+const defaultReportTop = 10
+const defaultReportSubject = "PiHole report"
+const defaultReportSMTPTLS = "starttls"
+const defaultReportSMTPPort = "587"
+const defaultReportSMTPTLSPort = "465"
+const defaultReportTimeout = 30
+const defaultReportOutput = "pihole-report.html"
+const defaultAgentXTimeout = 15
+
+const defaultAuthRealm = "pihole-stats-exporter"
+
+// results of the bcrypt comparison are cached, the cache is cleared if it is full
+const maxAuthCacheEntries = 1024
 
 const versionText = `%s version %s
 Copyright (C) 2020 by Andreas Maus <maus@ypbind.de>
@@ -20,12 +139,92 @@ Build with go version: %s
 
 `
 
-const helpText = `Usage: %s --config=<cfg> [--help] [--version]
+const helpText = `Usage: %s [check|munin|report] --config=<cfg> [--collectd] [--execd] [--help] [--version] [--zabbix-lld=<type>]
     --config=<cfg>  Path to the configuration file
                     This parameter is mandatory
 
+    --collectd      Run as plugin for the exec plugin of collectd, write PUTVAL
+                    commands to stdout instead of starting the HTTP server
+
+    --execd         Run as execd input plugin for Telegraf, write InfluxDB data
+                    to stdout for every newline on stdin or SIGUSR1 instead of
+                    starting the HTTP server
+
     --help          This help text
 
     --version       Show version information
 
+    --zabbix-lld=<type>
+                    Print Zabbix low-level discovery data and exit
+                    Supported types are querytypes, replies and upstreams
+
+    check           Run as Nagios/Icinga check plugin, see check --help
+
+    munin           Run as Munin plugin, see munin --help
+
+    report          Create the report and send it by email, see report --help
+
+
+`
+
+const checkHelpText = `Usage: %s check --config=<cfg> [--warn=<metric>=<range>] [--crit=<metric>=<range>]
+            [--warn-gravity-age=<age>] [--crit-gravity-age=<age>]
+            [--warn-block-ratio=<range>] [--crit-block-ratio=<range>]
+            [--warn-blocking-disabled] [--crit-blocking-disabled]
+    --config=<cfg>  Path to the configuration file
+                    This parameter is mandatory
+
+    --warn=<metric>=<range>
+    --crit=<metric>=<range>
+                    Warning/critical range for a metric, can be repeated
+                    Ranges use the syntax of the monitoring plugins ([@]start:end)
+
+    --warn-gravity-age=<age>
+    --crit-gravity-age=<age>
+                    Warning/critical if the last gravity update is older than <age>
+                    Supported units are s, m, h, d and w, e.g. 8d
+
+    --warn-block-ratio=<range>
+    --crit-block-ratio=<range>
+                    Warning/critical range for the ratio of blocked queries (0 - 1)
+
+    --warn-blocking-disabled
+    --crit-blocking-disabled
+                    Warning/critical if blocking is disabled
+
+Metrics are the names of the summary values (e.g. dns_queries_today), replies
+and query types prefixed by their group (e.g. reply.NXDOMAIN, querytypes.AAAA),
+blocking (1 or 0), block_ratio and gravity_age (in seconds).
+
+Exit codes are 0 (OK), 1 (WARNING), 2 (CRITICAL) and 3 (UNKNOWN).
+
+`
+
+const reportHelpText = `Usage: %s report --config=<cfg> [--dry-run] [--output=<file>] [--text-output=<file>] [--period=daily|weekly]
+    --config=<cfg>  Path to the configuration file
+                    This parameter is mandatory
+
+    --dry-run       Don't send the report, write it to a file instead
+
+    --output=<file> File for the HTML report in dry-run mode
+                    Default: %s
+
+    --text-output=<file>
+                    File for the plain text report in dry-run mode
+
+    --period=<period>
+                    Period of the report, daily or weekly
+                    Default: period from the report section of the
+                    configuration file
+
+`
+
+const muninHelpText = `Usage: %s munin --config=<cfg> [autoconf|config|fetch]
+    --config=<cfg>  Path to the configuration file
+                    This parameter is mandatory
+
+    autoconf        Report that the plugin can be used
+    config          Print the graph definitions
+    fetch           Print the values (default)
+
 `
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+var dashboardTemplate = htmltemplate.Must(htmltemplate.ParseFS(dashboardFiles, "dashboard/index.html"))
+
+// static files of the dashboard, served below the path of the dashboard
+var dashboardAssets = map[string]string{
+	"dashboard.js":  "application/javascript; charset=utf-8",
+	"dashboard.css": "text/css; charset=utf-8",
+}
+
+// registerDashboard - the page is served on dashboard_path, the assets next to it
+func registerDashboard(router *mux.Router) {
+	base := strings.TrimRight(config.Exporter.DashboardPath, "/")
+
+	router.HandleFunc(config.Exporter.DashboardPath, dashboardExporter)
+	for asset := range dashboardAssets {
+		router.HandleFunc(base+"/"+asset, dashboardAssetExporter)
+	}
+}
+
+func dashboardExporter(response http.ResponseWriter, request *http.Request) {
+	var page bytes.Buffer
+
+	log.WithFields(log.Fields{
+		"method":         request.Method,
+		"url":            request.URL.String(),
+		"protocol":       request.Proto,
+		"host":           request.Host,
+		"remote_address": request.RemoteAddr,
+		"headers":        fmt.Sprintf("%+v\n", request.Header),
+		"client_cert":    getClientIdentity(request),
+	}).Info(formatLogString("HTTP request from client received"))
+
+	response.Header().Add("X-Clacks-Overhead", "GNU Terry Pratchett")
+
+	data := DashboardData{
+		Name:          name,
+		Version:       version,
+		Instance:      config.PiHole.Instance,
+		Base:          strings.TrimRight(config.Exporter.DashboardPath, "/"),
+		JSONPath:      config.Exporter.JSONPath,
+		Interval:      config.Exporter.PollInterval,
+		GravityMaxAge: config.Events.GravityMaxAge,
+	}
+
+	// the dashboard only uses the endpoints which are enabled
+	if config.History.enabled {
+		data.HistoryPath = config.Exporter.HistoryPath
+	}
+	if len(config.Alerts) > 0 {
+		data.AlertsPath = config.Exporter.AlertsPath
+	}
+
+	err := dashboardTemplate.Execute(&page, data)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"remote_address": request.RemoteAddr,
+			"error":          err.Error(),
+		}).Error(formatLogString("Can't render dashboard"))
+
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Content-Security-Policy", "default-src 'self'; img-src 'self' data:")
+	response.Write(page.Bytes())
+}
+
+func dashboardAssetExporter(response http.ResponseWriter, request *http.Request) {
+	response.Header().Add("X-Clacks-Overhead", "GNU Terry Pratchett")
+
+	asset := request.URL.Path[strings.LastIndex(request.URL.Path, "/")+1:]
+
+	content, err := dashboardFiles.ReadFile("dashboard/" + asset)
+	if err != nil {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	response.Header().Set("Content-Type", dashboardAssets[asset])
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Write(content)
+}
@@ -0,0 +1,226 @@
+"use strict";
+
+// paths of the JSON endpoints are set by the exporter, empty if the endpoint is disabled
+var settings = document.body.dataset;
+var interval = Math.max(parseInt(settings.interval, 10) || 15, 5) * 1000;
+
+function formatNumber(value) {
+  return value.toLocaleString("en-US");
+}
+
+function formatPercent(ratio) {
+  return (100 * ratio).toFixed(1) + " %";
+}
+
+function formatAge(seconds) {
+  var days = Math.floor(seconds / 86400);
+  var hours = Math.floor((seconds % 86400) / 3600);
+
+  if (days > 0) {
+    return days + "d " + hours + "h ago";
+  }
+  return hours + "h " + Math.floor((seconds % 3600) / 60) + "m ago";
+}
+
+function fetchJSON(path) {
+  return fetch(path, {headers: {"Accept": "application/json"}, credentials: "same-origin"}).then(function (response) {
+    if (!response.ok) {
+      throw new Error(path + ": " + response.status + " " + response.statusText);
+    }
+    return response.json();
+  });
+}
+
+function fillTable(table, values, format) {
+  table.textContent = "";
+
+  Object.keys(values).sort().forEach(function (name) {
+    var row = table.insertRow();
+    row.insertCell().textContent = name;
+
+    var cell = row.insertCell();
+    cell.className = "number";
+    cell.textContent = format(values[name]);
+  });
+}
+
+// renderInstance - the card of an instance is created on the first update and reused afterwards
+function renderInstance(doc) {
+  var id = "instance-" + doc.instance.name;
+  var card = document.getElementById(id);
+
+  if (!card) {
+    card = document.getElementById("instance-template").content.firstElementChild.cloneNode(true);
+    card.id = id;
+    document.getElementById("instances").appendChild(card);
+  }
+
+  card.querySelector(".instance-name").textContent = doc.instance.name;
+  card.querySelector(".instance-url").textContent = doc.instance.url;
+
+  var badge = card.querySelector(".blocking");
+  if (doc.blocking) {
+    badge.textContent = "blocking " + doc.blocking.status;
+    badge.className = "badge blocking " + (doc.blocking.enabled ? "ok" : "problem");
+  } else {
+    badge.textContent = "unreachable";
+    badge.className = "badge blocking problem";
+  }
+
+  var errors = card.querySelector(".errors");
+  errors.textContent = "";
+  (doc.errors || []).forEach(function (error) {
+    var item = document.createElement("li");
+    item.textContent = error.request + ": " + error.message;
+    errors.appendChild(item);
+  });
+
+  card.querySelectorAll("[data-field]").forEach(function (element) {
+    var value = doc.summary ? doc.summary[element.dataset.field] : undefined;
+
+    if (value === undefined) {
+      element.textContent = "-";
+    } else if (element.dataset.field === "ads_ratio_today") {
+      element.textContent = formatPercent(value);
+    } else {
+      element.textContent = formatNumber(value);
+    }
+  });
+
+  var gravity = card.querySelector(".gravity");
+  if (doc.gravity && doc.gravity.file_exists) {
+    gravity.textContent = formatAge(doc.gravity.age_seconds);
+    gravity.title = new Date(doc.gravity.last_updated * 1000).toLocaleString();
+
+    var maxAge = parseInt(settings.gravityMaxAge, 10);
+    gravity.classList.toggle("warning", maxAge > 0 && doc.gravity.age_seconds > maxAge * 86400);
+  } else {
+    gravity.textContent = "-";
+  }
+
+  fillTable(card.querySelector(".replies"), doc.replies || {}, formatNumber);
+  fillTable(card.querySelector(".query-types"), doc.query_types || {}, formatPercent);
+
+  return card;
+}
+
+// increase - counters of the PiHole server are reset at midnight, the value after the reset is the increase
+function increase(values) {
+  var result = [];
+
+  for (var i = 1; i < values.length; i++) {
+    var diff = values[i][1] - values[i - 1][1];
+    result.push([values[i][0], diff >= 0 ? diff : values[i][1]]);
+  }
+
+  return result;
+}
+
+function drawSparkline(svg, values) {
+  var width = svg.clientWidth || 150;
+  var height = svg.clientHeight || 30;
+
+  svg.textContent = "";
+  svg.setAttribute("viewBox", "0 0 " + width + " " + height);
+
+  if (values.length < 2) {
+    return;
+  }
+
+  var first = values[0][0];
+  var last = values[values.length - 1][0];
+  var max = Math.max.apply(null, values.map(function (v) { return v[1]; }));
+  var min = Math.min.apply(null, values.map(function (v) { return v[1]; }));
+  var range = max - min || 1;
+
+  var points = values.map(function (v) {
+    var x = (v[0] - first) / (last - first || 1) * width;
+    var y = height - 1 - (v[1] - min) / range * (height - 2);
+    return x.toFixed(1) + "," + y.toFixed(1);
+  });
+
+  var line = document.createElementNS("http://www.w3.org/2000/svg", "polyline");
+  line.setAttribute("points", points.join(" "));
+  svg.appendChild(line);
+}
+
+// updateSparklines - last 24 hours from the history of the exporter
+function updateSparklines(card) {
+  if (!settings.historyPath) {
+    card.querySelectorAll(".sparkline").forEach(function (svg) {
+      svg.hidden = true;
+    });
+    return Promise.resolve();
+  }
+
+  var to = Math.floor(Date.now() / 1000);
+  var query = "?metric=dns_queries_today,ads_blocked_today,block_ratio&from=" + (to - 86400) + "&to=" + to;
+
+  return fetchJSON(settings.historyPath + query).then(function (history) {
+    var series = {};
+    history.series.forEach(function (s) {
+      series[s.metric] = s.values;
+    });
+
+    var lines = {
+      queries: increase(series.dns_queries_today || []),
+      blocked: increase(series.ads_blocked_today || []),
+      block_ratio: series.block_ratio || []
+    };
+
+    card.querySelectorAll(".sparkline").forEach(function (svg) {
+      drawSparkline(svg, lines[svg.dataset.series]);
+    });
+  });
+}
+
+function updateAlerts() {
+  var section = document.getElementById("alerts");
+
+  if (!settings.alertsPath) {
+    return Promise.resolve();
+  }
+
+  return fetchJSON(settings.alertsPath).then(function (doc) {
+    var active = doc.alerts.filter(function (alert) {
+      return alert.state === "pending" || alert.state === "firing";
+    });
+
+    var body = section.querySelector("tbody");
+    body.textContent = "";
+
+    active.forEach(function (alert) {
+      var row = body.insertRow();
+      row.insertCell().textContent = alert.name;
+
+      var state = document.createElement("span");
+      state.className = "badge " + (alert.state === "firing" ? "problem" : "pending");
+      state.textContent = alert.state;
+      row.insertCell().appendChild(state);
+
+      row.insertCell().textContent = alert.value === undefined ? "-" : alert.value;
+      row.insertCell().textContent = alert.annotations.summary || alert.expr;
+      row.insertCell().textContent = new Date(alert.active_at).toLocaleString();
+    });
+
+    section.hidden = active.length === 0;
+  });
+}
+
+function update() {
+  fetchJSON(settings.jsonPath).then(function (doc) {
+    document.getElementById("unreachable").hidden = true;
+    document.getElementById("updated").textContent = "updated " + new Date(doc.timestamp_unix * 1000).toLocaleTimeString();
+
+    var card = renderInstance(doc);
+    return Promise.all([updateSparklines(card), updateAlerts()]);
+  }).catch(function (error) {
+    var banner = document.getElementById("unreachable");
+    banner.textContent = "Can't fetch data from the exporter: " + error.message;
+    banner.hidden = false;
+  }).then(function () {
+    window.setTimeout(update, interval);
+  });
+}
+
+update();
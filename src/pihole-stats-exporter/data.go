@@ -1,7 +1,11 @@
 package main
 
 import (
+	"crypto/sha256"
+	"net"
 	"net/http"
+	"sync"
+	"text/template"
 	"time"
 )
 
@@ -43,6 +47,35 @@ type PiHoleQueriesByType struct {
 	NAPTR float64 `json:"NAPTR"`
 }
 
+// PiHoleVersions - installed and latest versions of the PiHole components
+type PiHoleVersions struct {
+	CoreUpdate  bool   `json:"core_update"`
+	WebUpdate   bool   `json:"web_update"`
+	FTLUpdate   bool   `json:"FTL_update"`
+	CoreCurrent string `json:"core_current"`
+	WebCurrent  string `json:"web_current"`
+	FTLCurrent  string `json:"FTL_current"`
+	CoreLatest  string `json:"core_latest"`
+	WebLatest   string `json:"web_latest"`
+	FTLLatest   string `json:"FTL_latest"`
+}
+
+// PiHoleForwardDestinations - share of forward destinations in percent, keys are "name|address"
+type PiHoleForwardDestinations struct {
+	ForwardDestinations map[string]float64 `json:"forward_destinations"`
+}
+
+// PiHoleTopItems - most frequent permitted and blocked domains, requires authentication
+type PiHoleTopItems struct {
+	TopQueries map[string]uint64 `json:"top_queries"`
+	TopAds     map[string]uint64 `json:"top_ads"`
+}
+
+// PiHoleQuerySources - most active clients, keys are "name|address" or the address, requires authentication
+type PiHoleQuerySources struct {
+	TopSources map[string]uint64 `json:"top_sources"`
+}
+
 // PiHoleGravityLastUpdated - information about last gravity update
 type PiHoleGravityLastUpdated struct {
 	FileExists bool                             `json:"file_exists"`
@@ -59,8 +92,31 @@ type PiHoleGravityLastUpdatedRelative struct {
 
 // Configuration - hold configuration information
 type Configuration struct {
-	PiHole   PiHoleConfiguration
-	Exporter ExporterConfiguration
+	PiHole        PiHoleConfiguration
+	Exporter      ExporterConfiguration
+	InfluxDB      InfluxDBConfiguration
+	RemoteWrite   RemoteWriteConfiguration
+	Pushgateway   PushgatewayConfiguration
+	Graphite      GraphiteConfiguration
+	StatsD        StatsDConfiguration
+	OTLP          OTLPConfiguration
+	MQTT          MQTTConfiguration
+	Zabbix        ZabbixConfiguration
+	Textfile      TextfileConfiguration
+	AgentX        AgentXConfiguration
+	Collectd      CollectdConfiguration
+	QueryLog      QueryLogConfiguration
+	Loki          LokiConfiguration
+	Syslog        SyslogConfiguration
+	Elasticsearch ElasticsearchConfiguration
+	Events        EventsConfiguration
+	Webhooks      []WebhookConfiguration
+	Alerts        []AlertConfiguration
+	Alertmanager  AlertmanagerConfiguration
+	Report        ReportConfiguration
+	History       HistoryConfiguration
+	Auth          AuthConfiguration
+	AuthPaths     []AuthPathConfiguration
 }
 
 // PiHoleConfiguration - Configure access to PiHole
@@ -71,16 +127,822 @@ type PiHoleConfiguration struct {
 	CAFile         string `ini:"ca_file"`
 	Timeout        uint   `ini:"timeout"`
 	FollowRedirect bool   `ini:"follow_redirect"`
+	Instance       string `ini:"instance"`
 	timeout        time.Duration
 }
 
 // ExporterConfiguration - configure metric exporter
 type ExporterConfiguration struct {
-	URL            string `ini:"url"`
-	PrometheusPath string `ini:"prometheus_path"`
-	InfluxDataPath string `ini:"influxdata_path"`
-	SSLCert        string `ini:"ssl_cert"`
-	SSLKey         string `ini:"ssl_key"`
+	URL                string `ini:"url"`
+	PrometheusPath     string `ini:"prometheus_path"`
+	InfluxDataPath     string `ini:"influxdata_path"`
+	JSONPath           string `ini:"json_path"`
+	SSEPath            string `ini:"sse_path"`
+	AlertsPath         string `ini:"alerts_path"`
+	HistoryPath        string `ini:"history_path"`
+	DashboardPath      string `ini:"dashboard_path"`
+	SSEHeartbeat       uint   `ini:"sse_heartbeat"`
+	SSEMaxSubscribers  uint   `ini:"sse_max_subscribers"`
+	PollInterval       uint   `ini:"poll_interval"`
+	SSLCert            string `ini:"ssl_cert"`
+	SSLKey             string `ini:"ssl_key"`
+	ClientCAFile       string `ini:"client_ca_file"`
+	ClientAuth         string `ini:"client_auth"`
+	ClientAllowedNames string `ini:"client_allowed_names"`
+	sseHeartbeat       time.Duration
+	pollInterval       time.Duration
+	clientAllowedNames map[string]bool
+}
+
+// InfluxDBConfiguration - configure push of metrics to InfluxDB
+type InfluxDBConfiguration struct {
+	URL             string `ini:"url"`
+	Version         uint   `ini:"version"`
+	Database        string `ini:"database"`
+	RetentionPolicy string `ini:"retention_policy"`
+	Username        string `ini:"username"`
+	Password        string `ini:"password"`
+	Org             string `ini:"org"`
+	Bucket          string `ini:"bucket"`
+	Token           string `ini:"token"`
+	Interval        uint   `ini:"interval"`
+	BatchSize       uint   `ini:"batch_size"`
+	Gzip            bool   `ini:"gzip"`
+	Retries         uint   `ini:"retries"`
+	Timeout         uint   `ini:"timeout"`
+	InsecureSSL     bool   `ini:"insecure_ssl"`
+	CAFile          string `ini:"ca_file"`
+	SpoolDir        string `ini:"spool_dir"`
+	SpoolMaxFiles   uint   `ini:"spool_max_files"`
+	enabled         bool
+	writeURL        string
+	interval        time.Duration
+	timeout         time.Duration
+}
+
+// InfluxDBPushStatistics - statistics of InfluxDB push operations
+type InfluxDBPushStatistics struct {
+	lock          sync.Mutex
+	Success       uint64
+	Failure       uint64
+	PointsWritten uint64
+	PointsSpooled uint64
+	PointsDropped uint64
+	SpoolFiles    uint64
+	LastSuccess   int64
+}
+
+// RemoteWriteConfiguration - configure Prometheus remote_write
+type RemoteWriteConfiguration struct {
+	URL             string `ini:"url"`
+	Interval        uint   `ini:"interval"`
+	Timeout         uint   `ini:"timeout"`
+	Username        string `ini:"username"`
+	Password        string `ini:"password"`
+	BearerToken     string `ini:"bearer_token"`
+	BearerTokenFile string `ini:"bearer_token_file"`
+	ExternalLabels  string `ini:"external_labels"`
+	QueueSize       uint   `ini:"queue_size"`
+	MinBackoff      uint   `ini:"min_backoff"`
+	MaxBackoff      uint   `ini:"max_backoff"`
+	InsecureSSL     bool   `ini:"insecure_ssl"`
+	CAFile          string `ini:"ca_file"`
+	enabled         bool
+	externalLabels  map[string]string
+	interval        time.Duration
+	timeout         time.Duration
+	minBackoff      time.Duration
+	maxBackoff      time.Duration
+}
+
+// PushgatewayConfiguration - configure push to Prometheus Pushgateway
+type PushgatewayConfiguration struct {
+	URL              string `ini:"url"`
+	Job              string `ini:"job"`
+	GroupingKey      string `ini:"grouping_key"`
+	Interval         uint   `ini:"interval"`
+	Timeout          uint   `ini:"timeout"`
+	Username         string `ini:"username"`
+	Password         string `ini:"password"`
+	InsecureSSL      bool   `ini:"insecure_ssl"`
+	CAFile           string `ini:"ca_file"`
+	DeleteOnShutdown bool   `ini:"delete_on_shutdown"`
+	enabled          bool
+	groupURL         string
+	interval         time.Duration
+	timeout          time.Duration
+}
+
+// GraphiteConfiguration - configure Graphite plaintext output
+type GraphiteConfiguration struct {
+	Address      string `ini:"address"`
+	Protocol     string `ini:"protocol"`
+	PathTemplate string `ini:"path_template"`
+	Interval     uint   `ini:"interval"`
+	Timeout      uint   `ini:"timeout"`
+	enabled      bool
+	interval     time.Duration
+	timeout      time.Duration
+}
+
+// StatsDConfiguration - configure StatsD/DogStatsD output
+type StatsDConfiguration struct {
+	Address   string `ini:"address"`
+	Protocol  string `ini:"protocol"`
+	Prefix    string `ini:"prefix"`
+	DogStatsD bool   `ini:"dogstatsd"`
+	Tags      string `ini:"tags"`
+	MTU       uint   `ini:"mtu"`
+	Interval  uint   `ini:"interval"`
+	enabled   bool
+	tags      map[string]string
+	interval  time.Duration
+}
+
+// OTLPConfiguration - configure OpenTelemetry OTLP/HTTP metric export
+type OTLPConfiguration struct {
+	URL         string `ini:"url"`
+	Headers     string `ini:"headers"`
+	Interval    uint   `ini:"interval"`
+	Timeout     uint   `ini:"timeout"`
+	Gzip        bool   `ini:"gzip"`
+	InsecureSSL bool   `ini:"insecure_ssl"`
+	CAFile      string `ini:"ca_file"`
+	enabled     bool
+	headers     map[string]string
+	interval    time.Duration
+	timeout     time.Duration
+}
+
+// MQTTConfiguration - configure publishing to a MQTT broker
+type MQTTConfiguration struct {
+	Broker                 string `ini:"broker"`
+	ClientID               string `ini:"client_id"`
+	Username               string `ini:"username"`
+	Password               string `ini:"password"`
+	TopicPrefix            string `ini:"topic_prefix"`
+	QoS                    uint   `ini:"qos"`
+	Retain                 bool   `ini:"retain"`
+	Interval               uint   `ini:"interval"`
+	Timeout                uint   `ini:"timeout"`
+	InsecureSSL            bool   `ini:"insecure_ssl"`
+	CAFile                 string `ini:"ca_file"`
+	HomeAssistantDiscovery bool   `ini:"homeassistant_discovery"`
+	DiscoveryPrefix        string `ini:"discovery_prefix"`
+	enabled                bool
+	interval               time.Duration
+	timeout                time.Duration
+}
+
+// ZabbixConfiguration - configure sending of data to a Zabbix server or proxy
+type ZabbixConfiguration struct {
+	Server    string `ini:"server"`
+	Host      string `ini:"host"`
+	KeyPrefix string `ini:"key_prefix"`
+	Interval  uint   `ini:"interval"`
+	Timeout   uint   `ini:"timeout"`
+	enabled   bool
+	address   string
+	interval  time.Duration
+	timeout   time.Duration
+}
+
+// TextfileConfiguration - configure output for the textfile collector of the node_exporter
+type TextfileConfiguration struct {
+	Path     string `ini:"path"`
+	Interval uint   `ini:"interval"`
+	enabled  bool
+	interval time.Duration
+}
+
+// QueryLogConfiguration - configure access to the query log in the FTL database
+type QueryLogConfiguration struct {
+	Database  string `ini:"database"`
+	BatchSize uint   `ini:"batch_size"`
+	enabled   bool
+}
+
+// QueryLogEntry - single DNS query from the query log
+type QueryLogEntry struct {
+	ID        int64
+	Timestamp time.Time
+	Type      string
+	Status    string
+	Blocked   bool
+	Domain    string
+	Client    string
+}
+
+// LokiConfiguration - configure streaming of the query log to Loki
+type LokiConfiguration struct {
+	URL         string `ini:"url"`
+	Username    string `ini:"username"`
+	Password    string `ini:"password"`
+	TenantID    string `ini:"tenant_id"`
+	CursorFile  string `ini:"cursor_file"`
+	Events      string `ini:"events"`
+	Interval    uint   `ini:"interval"`
+	Timeout     uint   `ini:"timeout"`
+	Retries     uint   `ini:"retries"`
+	InsecureSSL bool   `ini:"insecure_ssl"`
+	CAFile      string `ini:"ca_file"`
+	enabled     bool
+	pushURL     string
+	interval    time.Duration
+	timeout     time.Duration
+}
+
+// SyslogConfiguration - configure forwarding of the query log to syslog
+type SyslogConfiguration struct {
+	Server      string `ini:"server"`
+	Protocol    string `ini:"protocol"`
+	Framing     string `ini:"framing"`
+	Format      string `ini:"format"`
+	Facility    string `ini:"facility"`
+	Hostname    string `ini:"hostname"`
+	AppName     string `ini:"app_name"`
+	CursorFile  string `ini:"cursor_file"`
+	Events      string `ini:"events"`
+	Clients     string `ini:"clients"`
+	Domains     string `ini:"domains"`
+	RateLimit   uint   `ini:"rate_limit"`
+	Interval    uint   `ini:"interval"`
+	Timeout     uint   `ini:"timeout"`
+	InsecureSSL bool   `ini:"insecure_ssl"`
+	CAFile      string `ini:"ca_file"`
+	enabled     bool
+	address     string
+	facility    int
+	clientNets  []*net.IPNet
+	clientNames map[string]bool
+	domains     []string
+	interval    time.Duration
+	timeout     time.Duration
+}
+
+// ElasticsearchConfiguration - configure indexing to Elasticsearch or OpenSearch
+type ElasticsearchConfiguration struct {
+	URL             string `ini:"url"`
+	Username        string `ini:"username"`
+	Password        string `ini:"password"`
+	APIKey          string `ini:"api_key"`
+	StatsIndex      string `ini:"stats_index"`
+	QueriesIndex    string `ini:"queries_index"`
+	IndexRotation   string `ini:"index_rotation"`
+	InstallTemplate bool   `ini:"install_template"`
+	Snapshots       bool   `ini:"snapshots"`
+	Queries         bool   `ini:"queries"`
+	Events          string `ini:"events"`
+	CursorFile      string `ini:"cursor_file"`
+	Interval        uint   `ini:"interval"`
+	Timeout         uint   `ini:"timeout"`
+	Retries         uint   `ini:"retries"`
+	MaxBackoff      uint   `ini:"max_backoff"`
+	InsecureSSL     bool   `ini:"insecure_ssl"`
+	CAFile          string `ini:"ca_file"`
+	enabled         bool
+	bulkURL         string
+	templateURL     string
+	interval        time.Duration
+	timeout         time.Duration
+	maxBackoff      time.Duration
+}
+
+// ElasticsearchBulkMeta - action metadata of the bulk API
+type ElasticsearchBulkMeta struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id,omitempty"`
+}
+
+// ElasticsearchBulkResponse - response of the bulk API
+type ElasticsearchBulkResponse struct {
+	Errors bool                            `json:"errors"`
+	Items  []ElasticsearchBulkResponseItem `json:"items"`
+}
+
+// ElasticsearchBulkResponseItem - result of a single action of the bulk API
+type ElasticsearchBulkResponseItem struct {
+	Index ElasticsearchBulkResult `json:"index"`
+}
+
+// ElasticsearchBulkResult - result of an index action
+type ElasticsearchBulkResult struct {
+	Index  string              `json:"_index"`
+	ID     string              `json:"_id"`
+	Status int                 `json:"status"`
+	Error  *ElasticsearchError `json:"error"`
+}
+
+// ElasticsearchError - error reported by Elasticsearch
+type ElasticsearchError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// ElasticsearchQueryDocument - document of a single query
+type ElasticsearchQueryDocument struct {
+	Timestamp string `json:"@timestamp"`
+	Instance  string `json:"instance"`
+	ID        int64  `json:"id"`
+	Client    string `json:"client"`
+	Domain    string `json:"domain"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	Blocked   bool   `json:"blocked"`
+}
+
+// EventsConfiguration - configure detection of state changes
+type EventsConfiguration struct {
+	Interval         uint `ini:"interval"`
+	GravityMaxAge    uint `ini:"gravity_max_age"`
+	CheckUpdates     bool `ini:"check_updates"`
+	RenotifyInterval uint `ini:"renotify_interval"`
+	interval         time.Duration
+	gravityMaxAge    time.Duration
+	renotifyInterval time.Duration
+}
+
+// WebhookConfiguration - configure a webhook receiving notifications on state changes
+type WebhookConfiguration struct {
+	URL          string `ini:"url"`
+	Template     string `ini:"template"`
+	TemplateFile string `ini:"template_file"`
+	Events       string `ini:"events"`
+	ContentType  string `ini:"content_type"`
+	Headers      string `ini:"headers"`
+	Timeout      uint   `ini:"timeout"`
+	Retries      uint   `ini:"retries"`
+	InsecureSSL  bool   `ini:"insecure_ssl"`
+	CAFile       string `ini:"ca_file"`
+	name         string
+	events       map[string]bool
+	headers      map[string]string
+	template     *template.Template
+	timeout      time.Duration
+}
+
+// EventState - last known state of a condition
+type EventState struct {
+	Problem      bool
+	Since        time.Time
+	LastNotified time.Time
+}
+
+// EventObservation - state of a condition found by a check
+type EventObservation struct {
+	Condition string
+	Problem   bool
+	Message   string
+	Details   map[string]string
+}
+
+// Event - data passed to the templates of the webhooks
+type Event struct {
+	Name      string
+	Title     string
+	Condition string
+	Problem   bool
+	Repeat    bool
+	Message   string
+	Instance  string
+	Upstream  string
+	Timestamp time.Time
+	Since     time.Time
+	Details   map[string]string
+}
+
+// AlertConfiguration - alert rule evaluated after each poll
+type AlertConfiguration struct {
+	Expr        string `ini:"expr"`
+	Labels      string `ini:"labels"`
+	Summary     string `ini:"summary"`
+	Description string `ini:"description"`
+	name        string
+	metric      string
+	rate        bool
+	operator    string
+	threshold   float64
+	forDuration time.Duration
+	labels      map[string]string
+	annotations map[string]*template.Template
+}
+
+// AlertmanagerConfiguration - configure push of alerts to Alertmanager
+type AlertmanagerConfiguration struct {
+	URL          string `ini:"url"`
+	Username     string `ini:"username"`
+	Password     string `ini:"password"`
+	Interval     uint   `ini:"interval"`
+	Timeout      uint   `ini:"timeout"`
+	InsecureSSL  bool   `ini:"insecure_ssl"`
+	CAFile       string `ini:"ca_file"`
+	enabled      bool
+	alertsURL    string
+	generatorURL string
+	interval     time.Duration
+	timeout      time.Duration
+}
+
+// AlertState - state of an alert rule
+type AlertState struct {
+	State         string
+	Value         float64
+	HasValue      bool
+	ActiveAt      time.Time
+	FiredAt       time.Time
+	ResolvedAt    time.Time
+	Annotations   map[string]string
+	previousValue float64
+	previousTime  time.Time
+	lastSent      time.Time
+	resolvedSent  bool
+}
+
+// AlertTemplateData - data passed to the templates of the annotations
+type AlertTemplateData struct {
+	Name      string
+	Value     float64
+	Threshold float64
+	Labels    map[string]string
+}
+
+// AlertmanagerAlert - alert of the Alertmanager API v2
+type AlertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     string            `json:"startsAt,omitempty"`
+	EndsAt       string            `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// JSONAlertList - document provided by the alerts endpoint
+type JSONAlertList struct {
+	Alerts []JSONAlert `json:"alerts"`
+}
+
+// JSONAlert - state of a single alert rule
+type JSONAlert struct {
+	Name        string            `json:"name"`
+	State       string            `json:"state"`
+	Expr        string            `json:"expr"`
+	Value       *float64          `json:"value"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	ActiveAt    string            `json:"active_at,omitempty"`
+	FiredAt     string            `json:"fired_at,omitempty"`
+	ResolvedAt  string            `json:"resolved_at,omitempty"`
+}
+
+// HistoryConfiguration - configure the embedded store of past polls
+type HistoryConfiguration struct {
+	Retention    uint   `ini:"retention"`
+	Resolution   uint   `ini:"resolution"`
+	File         string `ini:"file"`
+	SaveInterval uint   `ini:"save_interval"`
+	enabled      bool
+	resolution   int64
+	slots        int64
+	saveInterval time.Duration
+}
+
+// HistoryStore - ring buffer of slots, the value of a slot is the last value polled within the slot, NaN if there is none
+type HistoryStore struct {
+	Resolution int64
+	Times      []int64
+	Values     map[string][]float64
+}
+
+// DashboardData - data passed to the template of the dashboard, paths of disabled endpoints are empty
+type DashboardData struct {
+	Name          string
+	Version       string
+	Instance      string
+	Base          string
+	JSONPath      string
+	HistoryPath   string
+	AlertsPath    string
+	Interval      uint
+	GravityMaxAge uint
+}
+
+// JSONHistoryInfo - available metrics and time range of the history
+type JSONHistoryInfo struct {
+	Resolution int64    `json:"resolution"`
+	Retention  int64    `json:"retention"`
+	Oldest     int64    `json:"oldest,omitempty"`
+	Newest     int64    `json:"newest,omitempty"`
+	Metrics    []string `json:"metrics"`
+}
+
+// JSONHistory - result of a history query
+type JSONHistory struct {
+	From   int64               `json:"from"`
+	To     int64               `json:"to"`
+	Step   int64               `json:"step"`
+	Series []JSONHistorySeries `json:"series"`
+}
+
+// JSONHistorySeries - values of a metric as pairs of timestamp and value
+type JSONHistorySeries struct {
+	Metric string       `json:"metric"`
+	Values [][2]float64 `json:"values"`
+}
+
+// ReportConfiguration - configure the report sent by email
+type ReportConfiguration struct {
+	Period      string `ini:"period"`
+	Schedule    string `ini:"schedule"`
+	Top         uint   `ini:"top"`
+	Subject     string `ini:"subject"`
+	From        string `ini:"from"`
+	To          string `ini:"to"`
+	SMTPServer  string `ini:"smtp_server"`
+	SMTPTLS     string `ini:"smtp_tls"`
+	Username    string `ini:"username"`
+	Password    string `ini:"password"`
+	InsecureSSL bool   `ini:"insecure_ssl"`
+	CAFile      string `ini:"ca_file"`
+	Timeout     uint   `ini:"timeout"`
+	enabled     bool
+	schedule    CronSchedule
+	to          []string
+	address     string
+	host        string
+	timeout     time.Duration
+}
+
+// CronSchedule - minutes, hours, days of month, months and days of week of a crontab(5) like schedule
+type CronSchedule struct {
+	minutes    map[int]bool
+	hours      map[int]bool
+	days       map[int]bool
+	months     map[int]bool
+	weekdays   map[int]bool
+	anyDay     bool
+	anyWeekday bool
+}
+
+// ReportData - data passed to the templates of the report
+type ReportData struct {
+	Name                string
+	Version             string
+	Instance            string
+	Upstream            string
+	Period              string
+	Source              string
+	From                time.Time
+	To                  time.Time
+	Generated           time.Time
+	Total               uint64
+	Blocked             uint64
+	Cached              uint64
+	Forwarded           uint64
+	BlockRatio          float64
+	UniqueDomains       uint64
+	UniqueClients       uint64
+	Blocking            string
+	DomainsBeingBlocked uint64
+	GravityUpdated      time.Time
+	GravityAgeDays      int64
+	TopDomains          []ReportItem
+	TopBlocked          []ReportItem
+	TopClients          []ReportItem
+	Upstreams           []ReportItem
+	Errors              []string
+}
+
+// ReportItem - entry of a top list or of the upstream split, share is in percent
+type ReportItem struct {
+	Name  string
+	Count uint64
+	Share float64
+}
+
+// LokiPushRequest - request of the Loki push API
+type LokiPushRequest struct {
+	Streams []LokiStream `json:"streams"`
+}
+
+// LokiStream - log lines of a stream, values are pairs of timestamp (nanoseconds as string) and line
+type LokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// LokiLogLine - log line of a query
+type LokiLogLine struct {
+	ID        int64  `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Client    string `json:"client"`
+	Domain    string `json:"domain"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+}
+
+// CollectdConfiguration - configure the collectd exec mode
+type CollectdConfiguration struct {
+	Host     string `ini:"host"`
+	Interval uint   `ini:"interval"`
+}
+
+// MuninGraph - graph of the Munin plugin
+type MuninGraph struct {
+	Name   string
+	Title  string
+	VLabel string
+	Info   string
+	Args   string
+	Stack  bool
+	Fields []MuninField
+}
+
+// MuninField - data source of a Munin graph, Value is group/name of the PiHole value
+type MuninField struct {
+	Name  string
+	Label string
+	Value string
+}
+
+// AgentXConfiguration - configure the AgentX subagent
+type AgentXConfiguration struct {
+	Master   string `ini:"master"`
+	Interval uint   `ini:"interval"`
+	Timeout  uint   `ini:"timeout"`
+	BaseOID  string `ini:"base_oid"`
+	enabled  bool
+	network  string
+	address  string
+	interval time.Duration
+	timeout  time.Duration
+	baseOID  []uint32
+}
+
+// AgentXHeader - header of an AgentX PDU
+type AgentXHeader struct {
+	Version       uint8
+	Type          uint8
+	Flags         uint8
+	SessionID     uint32
+	TransactionID uint32
+	PacketID      uint32
+	PayloadLength uint32
+}
+
+// AgentXVarBind - variable binding, Value is used for numeric types and Data for octet strings
+type AgentXVarBind struct {
+	Type  uint16
+	OID   []uint32
+	Value uint64
+	Data  []byte
+}
+
+// AgentXSearchRange - OID range of Get, GetNext and GetBulk requests
+type AgentXSearchRange struct {
+	Start   []uint32
+	End     []uint32
+	Include bool
+}
+
+// ZabbixSenderRequest - data sent using the Zabbix sender protocol
+type ZabbixSenderRequest struct {
+	Request string             `json:"request"`
+	Data    []ZabbixSenderItem `json:"data"`
+	Clock   int64              `json:"clock"`
+}
+
+// ZabbixSenderItem - value of a single trapper item
+type ZabbixSenderItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+}
+
+// ZabbixSenderResponse - reply of the Zabbix server or proxy
+type ZabbixSenderResponse struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+// ZabbixLLD - low-level discovery data
+type ZabbixLLD struct {
+	Data []map[string]string `json:"data"`
+}
+
+// MQTTDiscoveryConfig - Home Assistant MQTT discovery message
+type MQTTDiscoveryConfig struct {
+	Name              string              `json:"name"`
+	UniqueID          string              `json:"unique_id"`
+	StateTopic        string              `json:"state_topic"`
+	AvailabilityTopic string              `json:"availability_topic"`
+	UnitOfMeasurement string              `json:"unit_of_measurement,omitempty"`
+	StateClass        string              `json:"state_class,omitempty"`
+	PayloadOn         string              `json:"payload_on,omitempty"`
+	PayloadOff        string              `json:"payload_off,omitempty"`
+	Icon              string              `json:"icon,omitempty"`
+	Device            MQTTDiscoveryDevice `json:"device"`
+}
+
+// MQTTDiscoveryDevice - device information for Home Assistant MQTT discovery
+type MQTTDiscoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version"`
+}
+
+// NagiosRange - threshold range in the syntax of the monitoring plugins, [@]start:end
+type NagiosRange struct {
+	Start  float64
+	End    float64
+	Inside bool
+	Text   string
+}
+
+// CheckThresholdList - threshold ranges by metric name
+type CheckThresholdList map[string]NagiosRange
+
+// PiHoleValue - single value reported by the PiHole server
+type PiHoleValue struct {
+	Group string
+	Name  string
+	Value float64
+}
+
+// PrometheusSample - single sample of a metric
+type PrometheusSample struct {
+	Name   string
+	Type   string
+	Labels map[string]string
+	Value  float64
+}
+
+// PollSnapshot - result of the background poll, the ID is increased for every snapshot
+type PollSnapshot struct {
+	ID       uint64
+	Document JSONDocument
+}
+
+// JSONDocument - stable document provided by the JSON endpoint
+type JSONDocument struct {
+	SchemaVersion uint                `json:"schema_version"`
+	Exporter      JSONExporterInfo    `json:"exporter"`
+	Instance      JSONInstanceInfo    `json:"instance"`
+	Timestamp     string              `json:"timestamp"`
+	TimestampUnix int64               `json:"timestamp_unix"`
+	Errors        []JSONError         `json:"errors"`
+	Blocking      *JSONBlockingStatus `json:"blocking"`
+	Summary       *JSONSummary        `json:"summary"`
+	Replies       map[string]uint64   `json:"replies"`
+	QueryTypes    map[string]float64  `json:"query_types"`
+	Gravity       *JSONGravity        `json:"gravity"`
+}
+
+// JSONExporterInfo - name and version of the exporter
+type JSONExporterInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// JSONInstanceInfo - PiHole instance
+type JSONInstanceInfo struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// JSONError - error while fetching data from the PiHole server
+type JSONError struct {
+	Request string `json:"request"`
+	Message string `json:"message"`
+}
+
+// JSONBlockingStatus - blocking status of the PiHole server
+type JSONBlockingStatus struct {
+	Enabled bool   `json:"enabled"`
+	Status  string `json:"status"`
+}
+
+// JSONSummary - summary statistics
+type JSONSummary struct {
+	DomainsBeingBlocked uint64  `json:"domains_being_blocked"`
+	DNSQueriesToday     uint64  `json:"dns_queries_today"`
+	AdsBlockedToday     uint64  `json:"ads_blocked_today"`
+	AdsRatioToday       float64 `json:"ads_ratio_today"`
+	UniqueDomains       uint64  `json:"unique_domains"`
+	QueriesForwarded    uint64  `json:"queries_forwarded"`
+	QueriesCached       uint64  `json:"queries_cached"`
+	ClientsEverSeen     uint64  `json:"clients_ever_seen"`
+	UniqueClients       uint64  `json:"unique_clients"`
+	DNSQueriesAllTypes  uint64  `json:"dns_queries_all_types"`
+	PrivacyLevel        uint    `json:"privacy_level"`
+}
+
+// JSONGravity - information about the gravity database
+type JSONGravity struct {
+	FileExists  bool   `json:"file_exists"`
+	LastUpdated uint64 `json:"last_updated"`
+	AgeSeconds  uint64 `json:"age_seconds"`
 }
 
 // HTTPResult - result of the http_request calls
@@ -91,3 +953,25 @@ type HTTPResult struct {
 	Header     http.Header
 	Content    []byte
 }
+
+// AuthConfiguration - configure authentication of HTTP requests, methods is the policy of all paths without an own policy
+type AuthConfiguration struct {
+	UsersFile  string `ini:"users_file"`
+	TokensFile string `ini:"tokens_file"`
+	Realm      string `ini:"realm"`
+	Methods    string `ini:"methods"`
+	enabled    bool
+	methods    map[string]bool
+	users      map[string][]byte
+	tokens     [][sha256.Size]byte
+	dummyHash  []byte
+}
+
+// AuthPathConfiguration - authentication policy of a path and the paths below it, e.g. [auth_path "/dashboard"]
+type AuthPathConfiguration struct {
+	Methods string `ini:"methods"`
+	Users   string `ini:"users"`
+	path    string
+	methods map[string]bool
+	users   map[string]bool
+}
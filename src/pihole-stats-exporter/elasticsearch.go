@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var elasticsearchTemplatesInstalled bool
+
+func elasticsearchSender() {
+	log.WithFields(log.Fields{
+		"elasticsearch_url": config.Elasticsearch.URL,
+		"stats_index":       config.Elasticsearch.StatsIndex,
+		"queries_index":     config.Elasticsearch.QueriesIndex,
+		"index_rotation":    config.Elasticsearch.IndexRotation,
+		"snapshots":         config.Elasticsearch.Snapshots,
+		"queries":           config.Elasticsearch.Queries,
+		"interval":          config.Elasticsearch.interval.String(),
+	}).Info(formatLogString("Starting indexing to Elasticsearch/OpenSearch"))
+
+	ticker := time.NewTicker(config.Elasticsearch.interval)
+	for {
+		// the cluster may not be available on startup, install the templates as soon as it is
+		if config.Elasticsearch.InstallTemplate && !elasticsearchTemplatesInstalled {
+			elasticsearchTemplatesInstalled = installElasticsearchTemplates()
+		}
+
+		if config.Elasticsearch.Snapshots {
+			indexElasticsearchSnapshot()
+		}
+
+		if config.Elasticsearch.Queries {
+			indexElasticsearchQueries()
+		}
+
+		<-ticker.C
+	}
+}
+
+func indexElasticsearchSnapshot() {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	doc := generateElasticsearchSnapshot(rawsum, qtypes, now)
+
+	action, err := buildElasticsearchBulkAction(elasticsearchIndexName(config.Elasticsearch.StatsIndex, now), "", doc)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"elasticsearch_url": config.Elasticsearch.URL,
+			"error":             err.Error(),
+		}).Error(formatLogString("Can't encode statistics for Elasticsearch"))
+
+		return
+	}
+
+	// a snapshot is not sent again on the next run, a newer one is taken instead
+	bulkElasticsearch([][]byte{action})
+}
+
+// generateElasticsearchSnapshot - one document per snapshot, the values are grouped like in the PiHole API
+func generateElasticsearchSnapshot(rawsum PiHoleRawSummary, qtypes PiHoleQueryTypes, now time.Time) map[string]interface{} {
+	var doc = map[string]interface{}{
+		"@timestamp": now.UTC().Format(time.RFC3339Nano),
+		"instance":   config.PiHole.Instance,
+		"upstream":   config.PiHole.URL,
+		"blocking":   rawsum.Status == "enabled",
+	}
+
+	if rawsum.GravityLastUpdated.FileExists {
+		doc["gravity_last_updated"] = rawsum.GravityLastUpdated.Absolute
+	}
+
+	for _, v := range getPiHoleValues(rawsum, qtypes) {
+		group, found := doc[v.Group].(map[string]float64)
+		if !found {
+			group = make(map[string]float64)
+			doc[v.Group] = group
+		}
+		group[v.Name] = v.Value
+	}
+
+	return doc
+}
+
+// indexElasticsearchQueries - index all new queries, the cursor is only moved forward if the cluster accepted the documents
+func indexElasticsearchQueries() {
+	cursor, err := readQueryLogCursor(config.Elasticsearch.CursorFile)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"database":    config.QueryLog.Database,
+			"cursor_file": config.Elasticsearch.CursorFile,
+			"error":       err.Error(),
+		}).Error(formatLogString("Can't get cursor of the query log"))
+
+		return
+	}
+
+	for {
+		var actions [][]byte
+
+		entries, err := readQueryLog(cursor, config.QueryLog.BatchSize)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"database": config.QueryLog.Database,
+				"cursor":   cursor,
+				"error":    err.Error(),
+			}).Error(formatLogString("Can't read query log"))
+
+			return
+		}
+
+		if len(entries) == 0 {
+			return
+		}
+
+		for _, entry := range entries {
+			if config.Elasticsearch.Events == "blocked" && !entry.Blocked {
+				continue
+			}
+
+			// IDs start again at 1 if the FTL database is recreated, the timestamp keeps the document ID unique
+			id := fmt.Sprintf("%s-%d-%d", config.PiHole.Instance, entry.ID, entry.Timestamp.Unix())
+
+			action, err := buildElasticsearchBulkAction(elasticsearchIndexName(config.Elasticsearch.QueriesIndex, entry.Timestamp), id, ElasticsearchQueryDocument{
+				Timestamp: entry.Timestamp.UTC().Format(time.RFC3339Nano),
+				Instance:  config.PiHole.Instance,
+				ID:        entry.ID,
+				Client:    entry.Client,
+				Domain:    entry.Domain,
+				Type:      entry.Type,
+				Status:    entry.Status,
+				Blocked:   entry.Blocked,
+			})
+			if err != nil {
+				continue
+			}
+
+			actions = append(actions, action)
+		}
+
+		if len(actions) > 0 {
+			retry, err := bulkElasticsearch(actions)
+			if err != nil && retry {
+				return
+			}
+		}
+
+		cursor = entries[len(entries)-1].ID
+		err = writeQueryLogCursor(config.Elasticsearch.CursorFile, cursor)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"cursor_file": config.Elasticsearch.CursorFile,
+				"cursor":      cursor,
+				"error":       err.Error(),
+			}).Error(formatLogString("Can't write cursor of the query log"))
+
+			return
+		}
+
+		if uint(len(entries)) < config.QueryLog.BatchSize {
+			return
+		}
+	}
+}
+
+func elasticsearchIndexName(prefix string, t time.Time) string {
+	switch config.Elasticsearch.IndexRotation {
+	case "monthly":
+		return prefix + "-" + t.UTC().Format("2006.01")
+	case "none":
+		return prefix
+	default:
+		return prefix + "-" + t.UTC().Format("2006.01.02")
+	}
+}
+
+// buildElasticsearchBulkAction - action and source lines of the bulk API, documents with an ID are replaced if sent again
+func buildElasticsearchBulkAction(index string, id string, doc interface{}) ([]byte, error) {
+	meta := ElasticsearchBulkMeta{
+		Index: index,
+		ID:    id,
+	}
+
+	action, err := json.Marshal(map[string]ElasticsearchBulkMeta{"index": meta})
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(append(action, '\n'), source...), '\n'), nil
+}
+
+func elasticsearchHeader(contentType string) map[string]string {
+	header := map[string]string{
+		"Content-Type": contentType,
+	}
+
+	if config.Elasticsearch.APIKey != "" {
+		header["Authorization"] = "ApiKey " + config.Elasticsearch.APIKey
+	} else if config.Elasticsearch.Username != "" {
+		header["Authorization"] = basicAuthHeader(config.Elasticsearch.Username, config.Elasticsearch.Password)
+	}
+
+	return header
+}
+
+// bulkElasticsearch - send documents, documents rejected with 429 are sent again after a backoff, returns true if the documents should be sent again later
+func bulkElasticsearch(actions [][]byte) (bool, error) {
+	var err error
+	var result HTTPResult
+	var wait time.Duration
+	var pending = actions
+
+	backoff := time.Second
+	for attempt := uint(0); attempt <= config.Elasticsearch.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(wait)
+			if backoff < config.Elasticsearch.maxBackoff {
+				backoff *= 2
+			}
+		}
+		wait = backoff
+		if wait > config.Elasticsearch.maxBackoff {
+			wait = config.Elasticsearch.maxBackoff
+		}
+
+		result, err = httpRequest(config.Elasticsearch.bulkURL, "POST", elasticsearchHeader("application/x-ndjson"), bytes.Join(pending, nil), config.Elasticsearch.InsecureSSL, config.Elasticsearch.CAFile, config.Elasticsearch.timeout)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"elasticsearch_url": config.Elasticsearch.URL,
+				"attempt":           attempt + 1,
+				"error":             err.Error(),
+			}).Error(formatLogString("Can't send data to Elasticsearch"))
+
+			continue
+		}
+
+		if result.StatusCode == http.StatusTooManyRequests || result.StatusCode >= 500 {
+			log.WithFields(log.Fields{
+				"elasticsearch_url": config.Elasticsearch.URL,
+				"attempt":           attempt + 1,
+				"status_code":       result.StatusCode,
+				"status":            result.Status,
+				"documents":         len(pending),
+			}).Warning(formatLogString("Elasticsearch is overloaded or not available, backing off"))
+
+			err = fmt.Errorf("Unexpected HTTP status from Elasticsearch: %s", result.Status)
+
+			// the cluster may tell us how long to wait
+			seconds, perr := strconv.ParseUint(result.Header.Get("Retry-After"), 10, 32)
+			if perr == nil && seconds > 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+			continue
+		}
+
+		if result.StatusCode != http.StatusOK {
+			log.WithFields(log.Fields{
+				"elasticsearch_url": config.Elasticsearch.URL,
+				"status_code":       result.StatusCode,
+				"status":            result.Status,
+				"response":          strings.TrimSpace(string(result.Content)),
+			}).Error(formatLogString("Unexpected HTTP status from Elasticsearch, skipping data"))
+
+			return false, fmt.Errorf("Unexpected HTTP status from Elasticsearch: %s", result.Status)
+		}
+
+		var response ElasticsearchBulkResponse
+		err = json.Unmarshal(result.Content, &response)
+		if err != nil {
+			return false, err
+		}
+
+		if !response.Errors {
+			return false, nil
+		}
+
+		// the bulk API reports the result for each document, only documents rejected because of load are sent again
+		var retry [][]byte
+		for i, item := range response.Items {
+			if i >= len(pending) {
+				break
+			}
+
+			status := item.Index
+			if status.Status == http.StatusTooManyRequests {
+				retry = append(retry, pending[i])
+				continue
+			}
+
+			if status.Status >= 300 {
+				fields := log.Fields{
+					"elasticsearch_url": config.Elasticsearch.URL,
+					"index":             status.Index,
+					"id":                status.ID,
+					"status_code":       status.Status,
+				}
+				if status.Error != nil {
+					fields["error_type"] = status.Error.Type
+					fields["error"] = status.Error.Reason
+				}
+				log.WithFields(fields).Error(formatLogString("Elasticsearch rejected document, skipping document"))
+			}
+		}
+
+		if len(retry) == 0 {
+			return false, nil
+		}
+
+		log.WithFields(log.Fields{
+			"elasticsearch_url": config.Elasticsearch.URL,
+			"attempt":           attempt + 1,
+			"documents":         len(retry),
+		}).Warning(formatLogString("Elasticsearch rejected documents because of load, backing off"))
+
+		err = fmt.Errorf("%d documents rejected by Elasticsearch because of load", len(retry))
+		pending = retry
+	}
+
+	return true, err
+}
+
+// installElasticsearchTemplates - composable index templates (Elasticsearch 7.8+, OpenSearch 1.0+) for the statistics and the queries
+func installElasticsearchTemplates() bool {
+	var templates = map[string]map[string]interface{}{
+		config.Elasticsearch.StatsIndex: {
+			"dynamic_templates": []map[string]interface{}{
+				// percentages may be 0 in the first document, don't map them as integer
+				{"numbers": map[string]interface{}{
+					"match_mapping_type": "long",
+					"mapping":            map[string]string{"type": "double"},
+				}},
+			},
+			"properties": map[string]interface{}{
+				"@timestamp":           map[string]string{"type": "date"},
+				"instance":             map[string]string{"type": "keyword"},
+				"upstream":             map[string]string{"type": "keyword"},
+				"blocking":             map[string]string{"type": "boolean"},
+				"gravity_last_updated": map[string]string{"type": "date", "format": "epoch_second"},
+				"summary":              map[string]string{"type": "object"},
+				"reply":                map[string]string{"type": "object"},
+				"querytypes":           map[string]string{"type": "object"},
+			},
+		},
+		config.Elasticsearch.QueriesIndex: {
+			"properties": map[string]interface{}{
+				"@timestamp": map[string]string{"type": "date"},
+				"instance":   map[string]string{"type": "keyword"},
+				"id":         map[string]string{"type": "long"},
+				"client":     map[string]string{"type": "keyword"},
+				"domain":     map[string]string{"type": "keyword"},
+				"type":       map[string]string{"type": "keyword"},
+				"status":     map[string]string{"type": "keyword"},
+				"blocked":    map[string]string{"type": "boolean"},
+			},
+		},
+	}
+
+	for index, mappings := range templates {
+		pattern := index + "-*"
+		if config.Elasticsearch.IndexRotation == "none" {
+			pattern = index
+		}
+
+		payload, err := json.Marshal(map[string]interface{}{
+			"index_patterns": []string{pattern},
+			"priority":       100,
+			"template": map[string]interface{}{
+				"mappings": mappings,
+			},
+			"_meta": map[string]string{
+				"managed_by": name,
+			},
+		})
+		if err != nil {
+			return false
+		}
+
+		result, err := httpRequest(config.Elasticsearch.templateURL+index, "PUT", elasticsearchHeader("application/json"), payload, config.Elasticsearch.InsecureSSL, config.Elasticsearch.CAFile, config.Elasticsearch.timeout)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"elasticsearch_url": config.Elasticsearch.URL,
+				"template":          index,
+				"error":             err.Error(),
+			}).Error(formatLogString("Can't install index template"))
+
+			return false
+		}
+
+		if result.StatusCode != http.StatusOK {
+			log.WithFields(log.Fields{
+				"elasticsearch_url": config.Elasticsearch.URL,
+				"template":          index,
+				"status_code":       result.StatusCode,
+				"status":            result.Status,
+				"response":          strings.TrimSpace(string(result.Content)),
+			}).Error(formatLogString("Can't install index template"))
+
+			return false
+		}
+
+		log.WithFields(log.Fields{
+			"elasticsearch_url": config.Elasticsearch.URL,
+			"template":          index,
+			"index_patterns":    pattern,
+		}).Info(formatLogString("Index template installed"))
+	}
+
+	return true
+}
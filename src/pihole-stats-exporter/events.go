@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// eventConditions - conditions with the names of the events sent if the problem starts and ends
+var eventConditions = map[string][2]string{
+	"reachability": {"pihole_unreachable", "pihole_recovered"},
+	"blocking":     {"blocking_disabled", "blocking_enabled"},
+	"gravity":      {"gravity_outdated", "gravity_updated"},
+	"update":       {"update_available", "update_installed"},
+}
+
+var eventTitles = map[string]string{
+	"pihole_unreachable": "PiHole unreachable",
+	"pihole_recovered":   "PiHole recovered",
+	"blocking_disabled":  "Blocking disabled",
+	"blocking_enabled":   "Blocking enabled",
+	"gravity_outdated":   "Gravity database outdated",
+	"gravity_updated":    "Gravity database updated",
+	"update_available":   "Update available",
+	"update_installed":   "Update installed",
+}
+
+var eventStates = make(map[string]*EventState)
+
+// webhookTemplateFunctions - json quotes a value for use in JSON payloads
+var webhookTemplateFunctions = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		var buffer bytes.Buffer
+
+		// messages are not embedded in HTML, keep characters like < and > readable
+		enc := json.NewEncoder(&buffer)
+		enc.SetEscapeHTML(false)
+		err := enc.Encode(v)
+		return strings.TrimSpace(buffer.String()), err
+	},
+}
+
+func eventEngine() {
+	var names []string
+	for _, hook := range config.Webhooks {
+		names = append(names, hook.name)
+	}
+
+	log.WithFields(log.Fields{
+		"webhooks":          strings.Join(names, ","),
+		"interval":          config.Events.interval.String(),
+		"gravity_max_age":   config.Events.GravityMaxAge,
+		"check_updates":     config.Events.CheckUpdates,
+		"renotify_interval": config.Events.renotifyInterval.String(),
+	}).Info(formatLogString("Starting detection of state changes"))
+
+	ticker := time.NewTicker(config.Events.interval)
+	for {
+		for _, observation := range observeEventConditions(time.Now()) {
+			processEventObservation(observation, time.Now())
+		}
+		<-ticker.C
+	}
+}
+
+// observeEventConditions - the other conditions are unknown if the PiHole server is not reachable, their state is kept
+func observeEventConditions(now time.Time) []EventObservation {
+	var result []EventObservation
+
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return append(result, EventObservation{
+			Condition: "reachability",
+			Problem:   true,
+			Message:   fmt.Sprintf("PiHole server %s is not reachable: %s", config.PiHole.Instance, err.Error()),
+			Details:   map[string]string{"error": err.Error()},
+		})
+	}
+
+	result = append(result, EventObservation{
+		Condition: "reachability",
+		Problem:   false,
+		Message:   fmt.Sprintf("PiHole server %s is reachable", config.PiHole.Instance),
+	})
+
+	result = append(result, EventObservation{
+		Condition: "blocking",
+		Problem:   rawsum.Status != "enabled",
+		Message:   fmt.Sprintf("Blocking is %s on %s", rawsum.Status, config.PiHole.Instance),
+		Details:   map[string]string{"status": rawsum.Status},
+	})
+
+	if config.Events.GravityMaxAge > 0 && rawsum.GravityLastUpdated.FileExists {
+		updated := time.Unix(int64(rawsum.GravityLastUpdated.Absolute), 0)
+		age := now.Sub(updated)
+
+		result = append(result, EventObservation{
+			Condition: "gravity",
+			Problem:   age > config.Events.gravityMaxAge,
+			Message:   fmt.Sprintf("Gravity database on %s was last updated %d days ago", config.PiHole.Instance, int64(age.Hours()/24)),
+			Details: map[string]string{
+				"last_updated": updated.UTC().Format(time.RFC3339),
+				"age_days":     strconv.FormatInt(int64(age.Hours()/24), 10),
+				"max_age_days": strconv.FormatUint(uint64(config.Events.GravityMaxAge), 10),
+			},
+		})
+	}
+
+	if config.Events.CheckUpdates {
+		versions, err := getPiHoleVersions()
+		if err == nil {
+			result = append(result, observeUpdates(versions))
+		}
+	}
+
+	return result
+}
+
+func observeUpdates(versions PiHoleVersions) EventObservation {
+	var updates []string
+	var details = make(map[string]string)
+
+	for _, component := range []struct {
+		name    string
+		update  bool
+		current string
+		latest  string
+	}{
+		{name: "core", update: versions.CoreUpdate, current: versions.CoreCurrent, latest: versions.CoreLatest},
+		{name: "web", update: versions.WebUpdate, current: versions.WebCurrent, latest: versions.WebLatest},
+		{name: "FTL", update: versions.FTLUpdate, current: versions.FTLCurrent, latest: versions.FTLLatest},
+	} {
+		details[component.name+"_current"] = component.current
+		details[component.name+"_latest"] = component.latest
+
+		if component.update {
+			updates = append(updates, fmt.Sprintf("%s %s -> %s", component.name, component.current, component.latest))
+		}
+	}
+
+	if len(updates) > 0 {
+		return EventObservation{
+			Condition: "update",
+			Problem:   true,
+			Message:   fmt.Sprintf("Updates available on %s: %s", config.PiHole.Instance, strings.Join(updates, ", ")),
+			Details:   details,
+		}
+	}
+
+	return EventObservation{
+		Condition: "update",
+		Problem:   false,
+		Message:   fmt.Sprintf("PiHole on %s is up to date", config.PiHole.Instance),
+		Details:   details,
+	}
+}
+
+// processEventObservation - notify on changes of the state, a problem already present on startup is notified too
+func processEventObservation(observation EventObservation, now time.Time) {
+	state, known := eventStates[observation.Condition]
+	if !known {
+		state = &EventState{
+			Problem: observation.Problem,
+			Since:   now,
+		}
+		eventStates[observation.Condition] = state
+
+		if observation.Problem {
+			notifyEvent(observation, state, false, now)
+		}
+		return
+	}
+
+	if observation.Problem != state.Problem {
+		state.Problem = observation.Problem
+		state.Since = now
+		notifyEvent(observation, state, false, now)
+		return
+	}
+
+	if state.Problem && config.Events.renotifyInterval > 0 && now.Sub(state.LastNotified) >= config.Events.renotifyInterval {
+		notifyEvent(observation, state, true, now)
+	}
+}
+
+func notifyEvent(observation EventObservation, state *EventState, repeat bool, now time.Time) {
+	name := eventConditions[observation.Condition][1]
+	if observation.Problem {
+		name = eventConditions[observation.Condition][0]
+	}
+
+	event := Event{
+		Name:      name,
+		Title:     eventTitles[name],
+		Condition: observation.Condition,
+		Problem:   observation.Problem,
+		Repeat:    repeat,
+		Message:   observation.Message,
+		Instance:  config.PiHole.Instance,
+		Upstream:  config.PiHole.URL,
+		Timestamp: now,
+		Since:     state.Since,
+		Details:   observation.Details,
+	}
+	if event.Details == nil {
+		event.Details = make(map[string]string)
+	}
+
+	if repeat {
+		log.WithFields(log.Fields{
+			"event":   event.Name,
+			"since":   event.Since.Format(time.RFC3339),
+			"message": event.Message,
+		}).Info(formatLogString("Problem persists, sending notifications again"))
+	} else {
+		log.WithFields(log.Fields{
+			"event":   event.Name,
+			"message": event.Message,
+		}).Info(formatLogString("State change detected, sending notifications"))
+	}
+
+	state.LastNotified = now
+
+	for _, hook := range config.Webhooks {
+		if len(hook.events) > 0 && !hook.events[event.Name] {
+			continue
+		}
+
+		sendWebhook(hook, event)
+	}
+}
+
+func sendWebhook(hook WebhookConfiguration, event Event) {
+	var payload bytes.Buffer
+	var result HTTPResult
+
+	err := hook.template.Execute(&payload, event)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"webhook": hook.name,
+			"event":   event.Name,
+			"error":   err.Error(),
+		}).Error(formatLogString("Can't render webhook template"))
+
+		return
+	}
+
+	header := map[string]string{
+		"Content-Type": hook.ContentType,
+	}
+	for key, value := range hook.headers {
+		header[key] = value
+	}
+
+	backoff := time.Second
+	for attempt := uint(0); attempt <= hook.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+
+		result, err = httpRequest(hook.URL, "POST", header, payload.Bytes(), hook.InsecureSSL, hook.CAFile, hook.timeout)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"webhook": hook.name,
+				"event":   event.Name,
+				"attempt": attempt + 1,
+				"error":   err.Error(),
+			}).Error(formatLogString("Can't send notification to webhook"))
+
+			continue
+		}
+
+		if result.StatusCode >= 200 && result.StatusCode < 300 {
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"webhook":     hook.name,
+			"event":       event.Name,
+			"attempt":     attempt + 1,
+			"status_code": result.StatusCode,
+			"status":      result.Status,
+			"response":    strings.TrimSpace(string(result.Content)),
+		}).Error(formatLogString("Unexpected HTTP status from webhook"))
+
+		// client errors (e.g. invalid payload, wrong token) will not be fixed by sending the notification again
+		if result.StatusCode < 500 && result.StatusCode != http.StatusTooManyRequests {
+			return
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// serialise output, a newline on stdin and SIGUSR1 may arrive at the same time
+var execdLock sync.Mutex
+
+// runExecd - write InfluxDB line protocol to stdout for every newline on stdin or SIGUSR1, the channel is closed if stdin is closed
+func runExecd(done chan bool) {
+	// stdout is reserved for the data
+	log.SetOutput(os.Stderr)
+
+	log.Info(formatLogString("Starting execd mode, waiting for newline on stdin or SIGUSR1"))
+
+	usr1Chan := make(chan os.Signal, 1)
+	signal.Notify(usr1Chan, syscall.SIGUSR1)
+
+	go func() {
+		for range usr1Chan {
+			writeExecd()
+		}
+	}()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		writeExecd()
+	}
+
+	// Telegraf closes stdin if the process should terminate
+	if scanner.Err() != nil {
+		log.WithFields(log.Fields{
+			"error": scanner.Err().Error(),
+		}).Error(formatLogString("Can't read from stdin"))
+	} else {
+		log.Info(formatLogString("Stdin was closed"))
+	}
+
+	close(done)
+}
+
+func writeExecd() {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	payload := generateInfluxData(rawsum, qtypes, time.Now().UnixNano(), false)
+
+	execdLock.Lock()
+	defer execdLock.Unlock()
+
+	_, err = os.Stdout.Write(payload)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error(formatLogString("Can't write data to stdout"))
+	}
+}
@@ -2,7 +2,13 @@ package main
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
 )
 
 func generateTLSConfiguration(cfg *Configuration) (*tls.Config, error) {
@@ -22,5 +28,84 @@ func generateTLSConfiguration(cfg *Configuration) (*tls.Config, error) {
 		},
 	}
 
+	if cfg.Exporter.ClientAuth == "none" {
+		return result, nil
+	}
+
+	cadata, err := ioutil.ReadFile(cfg.Exporter.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cacerts := x509.NewCertPool()
+	if !cacerts.AppendCertsFromPEM(cadata) {
+		return nil, fmt.Errorf("Can't append CA data of client certificates to CA pool")
+	}
+	result.ClientCAs = cacerts
+
+	// request: a certificate is verified if the client sends one, clients without a certificate are accepted
+	result.ClientAuth = tls.RequireAndVerifyClientCert
+	if cfg.Exporter.ClientAuth == "request" {
+		result.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	if len(cfg.Exporter.clientAllowedNames) > 0 {
+		result.VerifyConnection = verifyClientCertificateName
+	}
+
 	return result, nil
 }
+
+// verifyClientCertificateName - the common name or one of the subject alternative names must be in client_allowed_names
+func verifyClientCertificateName(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("No client certificate received")
+	}
+
+	cert := state.PeerCertificates[0]
+	names := getCertificateNames(cert)
+	for _, n := range names {
+		if config.Exporter.clientAllowedNames[strings.ToLower(n)] {
+			return nil
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"subject": cert.Subject.String(),
+		"names":   strings.Join(names, ","),
+	}).Warning(formatLogString("Client certificate is not allowed, rejecting connection"))
+
+	return fmt.Errorf("Name of client certificate %s is not allowed", cert.Subject.String())
+}
+
+// getCertificateNames - common name and subject alternative names of a certificate
+func getCertificateNames(cert *x509.Certificate) []string {
+	var names []string
+
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+
+	return names
+}
+
+// getClientIdentity - name of the verified client certificate for the logs, empty without a client certificate
+func getClientIdentity(request *http.Request) string {
+	if request.TLS == nil || len(request.TLS.VerifiedChains) == 0 || len(request.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+
+	names := getCertificateNames(request.TLS.VerifiedChains[0][0])
+	if len(names) == 0 {
+		return request.TLS.VerifiedChains[0][0].Subject.String()
+	}
+	return names[0]
+}
@@ -4,18 +4,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	log "github.com/sirupsen/logrus"
 )
 
-func getPiHoleRawSummary(request *http.Request) (PiHoleRawSummary, error) {
+func getPiHoleRawSummary() (PiHoleRawSummary, error) {
 	var rawsum PiHoleRawSummary
 
 	// get raw summary
 	result, err := fetchPiHoleData(config, "summaryRaw")
 	if err != nil {
 		log.WithFields(log.Fields{
-			"remote_address": request.RemoteAddr,
 			"error":          err.Error(),
 			"pihole_request": "summaryRaw",
 		}).Error(formatLogString("Can't fetch data from PiHole server"))
@@ -25,7 +25,6 @@ func getPiHoleRawSummary(request *http.Request) (PiHoleRawSummary, error) {
 
 	if result.StatusCode != http.StatusOK {
 		log.WithFields(log.Fields{
-			"remote_address": request.RemoteAddr,
 			"status_code":    result.StatusCode,
 			"status":         result.Status,
 			"pihole_request": "summaryRaw",
@@ -47,14 +46,13 @@ func getPiHoleRawSummary(request *http.Request) (PiHoleRawSummary, error) {
 	return rawsum, nil
 }
 
-func getPiHoleQueryTypes(request *http.Request) (PiHoleQueryTypes, error) {
+func getPiHoleQueryTypes() (PiHoleQueryTypes, error) {
 	var qtypes PiHoleQueryTypes
 
 	// get DNS queries by type
 	result, err := fetchPiHoleData(config, "getQueryTypes")
 	if err != nil {
 		log.WithFields(log.Fields{
-			"remote_address": request.RemoteAddr,
 			"error":          err.Error(),
 			"pihole_request": "getQueryTypes",
 		}).Error(formatLogString("Can't fetch data from PiHole server"))
@@ -64,7 +62,6 @@ func getPiHoleQueryTypes(request *http.Request) (PiHoleQueryTypes, error) {
 
 	if result.StatusCode != http.StatusOK {
 		log.WithFields(log.Fields{
-			"remote_address": request.RemoteAddr,
 			"status_code":    result.StatusCode,
 			"status":         result.Status,
 			"pihole_request": "getQueryTypes",
@@ -85,3 +82,153 @@ func getPiHoleQueryTypes(request *http.Request) (PiHoleQueryTypes, error) {
 
 	return qtypes, nil
 }
+
+func getPiHoleForwardDestinations() (PiHoleForwardDestinations, error) {
+	var fwd PiHoleForwardDestinations
+
+	// get share of forward destinations, requires authentication
+	result, err := fetchPiHoleData(config, "getForwardDestinations")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err.Error(),
+			"pihole_request": "getForwardDestinations",
+		}).Error(formatLogString("Can't fetch data from PiHole server"))
+
+		return fwd, err
+	}
+
+	if result.StatusCode != http.StatusOK {
+		log.WithFields(log.Fields{
+			"status_code":    result.StatusCode,
+			"status":         result.Status,
+			"pihole_request": "getForwardDestinations",
+		}).Error(formatLogString("Unexpected HTTP status from PiHole server"))
+
+		return fwd, fmt.Errorf("Unexpected HTTP status from PiHole server")
+	}
+
+	err = json.Unmarshal(result.Content, &fwd)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err.Error(),
+			"pihole_request": "getForwardDestinations",
+		}).Error(formatLogString("Can't decode received result as JSON data"))
+
+		return fwd, err
+	}
+
+	return fwd, nil
+}
+
+func getPiHoleVersions() (PiHoleVersions, error) {
+	var versions PiHoleVersions
+
+	// get installed and available versions of the PiHole components
+	result, err := fetchPiHoleData(config, "versions")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err.Error(),
+			"pihole_request": "versions",
+		}).Error(formatLogString("Can't fetch data from PiHole server"))
+
+		return versions, err
+	}
+
+	if result.StatusCode != http.StatusOK {
+		log.WithFields(log.Fields{
+			"status_code":    result.StatusCode,
+			"status":         result.Status,
+			"pihole_request": "versions",
+		}).Error(formatLogString("Unexpected HTTP status from PiHole server"))
+
+		return versions, fmt.Errorf("Unexpected HTTP status from PiHole server")
+	}
+
+	err = json.Unmarshal(result.Content, &versions)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err.Error(),
+			"pihole_request": "versions",
+		}).Error(formatLogString("Can't decode received result as JSON data"))
+
+		return versions, err
+	}
+
+	return versions, nil
+}
+
+func getPiHoleTopItems(count uint) (PiHoleTopItems, error) {
+	var top PiHoleTopItems
+
+	// get most frequent permitted and blocked domains, requires authentication
+	request := "topItems=" + strconv.FormatUint(uint64(count), 10)
+	result, err := fetchPiHoleData(config, request)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err.Error(),
+			"pihole_request": "topItems",
+		}).Error(formatLogString("Can't fetch data from PiHole server"))
+
+		return top, err
+	}
+
+	if result.StatusCode != http.StatusOK {
+		log.WithFields(log.Fields{
+			"status_code":    result.StatusCode,
+			"status":         result.Status,
+			"pihole_request": "topItems",
+		}).Error(formatLogString("Unexpected HTTP status from PiHole server"))
+
+		return top, fmt.Errorf("Unexpected HTTP status from PiHole server")
+	}
+
+	err = json.Unmarshal(result.Content, &top)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err.Error(),
+			"pihole_request": "topItems",
+		}).Error(formatLogString("Can't decode received result as JSON data"))
+
+		return top, err
+	}
+
+	return top, nil
+}
+
+func getPiHoleQuerySources(count uint) (PiHoleQuerySources, error) {
+	var sources PiHoleQuerySources
+
+	// get most active clients, requires authentication
+	request := "getQuerySources=" + strconv.FormatUint(uint64(count), 10)
+	result, err := fetchPiHoleData(config, request)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err.Error(),
+			"pihole_request": "getQuerySources",
+		}).Error(formatLogString("Can't fetch data from PiHole server"))
+
+		return sources, err
+	}
+
+	if result.StatusCode != http.StatusOK {
+		log.WithFields(log.Fields{
+			"status_code":    result.StatusCode,
+			"status":         result.Status,
+			"pihole_request": "getQuerySources",
+		}).Error(formatLogString("Unexpected HTTP status from PiHole server"))
+
+		return sources, fmt.Errorf("Unexpected HTTP status from PiHole server")
+	}
+
+	err = json.Unmarshal(result.Content, &sources)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err.Error(),
+			"pihole_request": "getQuerySources",
+		}).Error(formatLogString("Can't decode received result as JSON data"))
+
+		return sources, err
+	}
+
+	return sources, nil
+}
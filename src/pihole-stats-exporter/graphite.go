@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maximal size of a single UDP datagram, small enough to avoid fragmentation on common links
+const graphiteMaxUDPPayload = 1400
+
+var graphiteInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+var graphiteConnection net.Conn
+
+func graphiteSender() {
+	log.WithFields(log.Fields{
+		"graphite_address": config.Graphite.Address,
+		"protocol":         config.Graphite.Protocol,
+		"path_template":    config.Graphite.PathTemplate,
+		"interval":         config.Graphite.interval.String(),
+	}).Info(formatLogString("Starting Graphite output"))
+
+	ticker := time.NewTicker(config.Graphite.interval)
+	for {
+		sendGraphite()
+		<-ticker.C
+	}
+}
+
+func sendGraphite() {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	lines := generateGraphiteData(getPiHoleValues(rawsum, qtypes), time.Now().Unix())
+
+	err = writeGraphite(lines)
+	if err != nil {
+		// the connection may have been closed by the server, try again with a new connection
+		closeGraphite()
+		err = writeGraphite(lines)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"graphite_address": config.Graphite.Address,
+			"protocol":         config.Graphite.Protocol,
+			"error":            err.Error(),
+		}).Error(formatLogString("Can't send data to Graphite"))
+
+		closeGraphite()
+	}
+}
+
+func generateGraphiteData(values []PiHoleValue, now int64) [][]byte {
+	var result [][]byte
+
+	replacer := strings.NewReplacer(
+		"{instance}", sanitizeGraphiteName(config.PiHole.Instance),
+		"{upstream}", sanitizeGraphiteName(config.PiHole.URL),
+	)
+	template := replacer.Replace(config.Graphite.PathTemplate)
+
+	for _, v := range values {
+		path := strings.NewReplacer(
+			"{metric}", sanitizeGraphiteName(v.Group)+"."+sanitizeGraphiteName(v.Name),
+			"{group}", sanitizeGraphiteName(v.Group),
+			"{name}", sanitizeGraphiteName(v.Name),
+		).Replace(template)
+
+		result = append(result, []byte(path+" "+strconv.FormatFloat(v.Value, 'f', -1, 64)+" "+strconv.FormatInt(now, 10)+"\n"))
+	}
+
+	return result
+}
+
+// sanitizeGraphiteName - dots are path separators in Graphite, replace them and all other special characters
+func sanitizeGraphiteName(s string) string {
+	return strings.Trim(graphiteInvalidChars.ReplaceAllString(s, "_"), "_")
+}
+
+func writeGraphite(lines [][]byte) error {
+	var err error
+
+	if graphiteConnection == nil {
+		graphiteConnection, err = net.DialTimeout(config.Graphite.Protocol, config.Graphite.Address, config.Graphite.timeout)
+		if err != nil {
+			graphiteConnection = nil
+			return err
+		}
+
+		log.WithFields(log.Fields{
+			"graphite_address": config.Graphite.Address,
+			"protocol":         config.Graphite.Protocol,
+		}).Info(formatLogString("Connected to Graphite"))
+	}
+
+	err = graphiteConnection.SetWriteDeadline(time.Now().Add(config.Graphite.timeout))
+	if err != nil {
+		return err
+	}
+
+	if config.Graphite.Protocol == "tcp" {
+		_, err = graphiteConnection.Write(bytes.Join(lines, nil))
+		return err
+	}
+
+	// UDP, pack as many lines as possible into a single datagram
+	var datagram []byte
+	for _, line := range lines {
+		if len(datagram)+len(line) > graphiteMaxUDPPayload && len(datagram) > 0 {
+			_, err = graphiteConnection.Write(datagram)
+			if err != nil {
+				return err
+			}
+			datagram = nil
+		}
+		datagram = append(datagram, line...)
+	}
+
+	if len(datagram) > 0 {
+		_, err = graphiteConnection.Write(datagram)
+	}
+	return err
+}
+
+func closeGraphite() {
+	if graphiteConnection != nil {
+		graphiteConnection.Close()
+		graphiteConnection = nil
+	}
+}
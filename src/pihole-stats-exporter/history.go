@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	log "github.com/sirupsen/logrus"
+)
+
+var historyLock sync.Mutex
+var historyStore *HistoryStore
+
+func newHistoryStore(resolution int64, slots int64) *HistoryStore {
+	return &HistoryStore{
+		Resolution: resolution,
+		Times:      make([]int64, slots),
+		Values:     make(map[string][]float64),
+	}
+}
+
+// add - the slot is reused if it still holds values from the previous round of the ring buffer
+func (h *HistoryStore) add(timestamp int64, metrics map[string]float64) {
+	slot := timestamp - timestamp%h.Resolution
+	index := (slot / h.Resolution) % int64(len(h.Times))
+
+	if h.Times[index] != slot {
+		h.Times[index] = slot
+		for _, values := range h.Values {
+			values[index] = math.NaN()
+		}
+	}
+
+	for name, value := range metrics {
+		values, found := h.Values[name]
+		if !found {
+			values = make([]float64, len(h.Times))
+			for i := range values {
+				values[i] = math.NaN()
+			}
+			h.Values[name] = values
+		}
+		values[index] = value
+	}
+}
+
+// get - value of the slot starting at slot, false if there is none
+func (h *HistoryStore) get(name string, slot int64) (float64, bool) {
+	values, found := h.Values[name]
+	if !found {
+		return 0, false
+	}
+
+	index := (slot / h.Resolution) % int64(len(h.Times))
+	if h.Times[index] != slot || math.IsNaN(values[index]) {
+		return 0, false
+	}
+
+	return values[index], true
+}
+
+// query - one point every step from from to to, the value of a point is the last value within the step before it,
+// only slots from oldest to newest (see bounds) are visited
+func (h *HistoryStore) query(name string, from int64, to int64, step int64, oldest int64, newest int64) [][2]float64 {
+	var result = make([][2]float64, 0)
+
+	// points before the oldest slot can't have a value
+	if from < oldest {
+		from += (oldest - from + step - 1) / step * step
+	}
+
+	for t := from; t <= to; t += step {
+		last := t - t%h.Resolution
+		if last > newest {
+			last = newest
+		}
+
+		for slot := last; slot > t-step && slot >= oldest; slot -= h.Resolution {
+			value, ok := h.get(name, slot)
+			if ok {
+				result = append(result, [2]float64{float64(t), value})
+				break
+			}
+		}
+
+		// t + step would overflow
+		if t > math.MaxInt64-step {
+			break
+		}
+	}
+
+	return result
+}
+
+// bounds - oldest and newest slot in use
+func (h *HistoryStore) bounds() (int64, int64) {
+	var oldest int64
+	var newest int64
+
+	for _, slot := range h.Times {
+		if slot == 0 {
+			continue
+		}
+		if oldest == 0 || slot < oldest {
+			oldest = slot
+		}
+		if slot > newest {
+			newest = slot
+		}
+	}
+
+	return oldest, newest
+}
+
+func initHistory() {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	historyStore = newHistoryStore(config.History.resolution, config.History.slots)
+
+	if config.History.File == "" {
+		return
+	}
+
+	err := loadHistory(config.History.File)
+	if err != nil && !os.IsNotExist(err) {
+		log.WithFields(log.Fields{
+			"file":  config.History.File,
+			"error": err.Error(),
+		}).Error(formatLogString("Can't load history, starting with an empty history"))
+	}
+}
+
+// loadHistory - values are added again, so changes of resolution and retention are applied to the saved history
+func loadHistory(file string) error {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	data, err := snappy.Decode(nil, raw)
+	if err != nil {
+		return err
+	}
+
+	var saved HistoryStore
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&saved)
+	if err != nil {
+		return err
+	}
+
+	var count uint64
+	for index, slot := range saved.Times {
+		if slot == 0 || slot <= time.Now().Unix()-int64(config.History.Retention)*86400 {
+			continue
+		}
+
+		metrics := make(map[string]float64)
+		for name, values := range saved.Values {
+			if index < len(values) && !math.IsNaN(values[index]) {
+				metrics[name] = values[index]
+			}
+		}
+
+		if len(metrics) > 0 {
+			historyStore.add(slot, metrics)
+			count++
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"file":  file,
+		"slots": count,
+	}).Info(formatLogString("History loaded"))
+
+	return nil
+}
+
+func saveHistory() {
+	var buffer bytes.Buffer
+
+	historyLock.Lock()
+	err := gob.NewEncoder(&buffer).Encode(historyStore)
+	historyLock.Unlock()
+
+	if err == nil {
+		err = writeFileAtomic(config.History.File, snappy.Encode(nil, buffer.Bytes()))
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"file":  config.History.File,
+			"error": err.Error(),
+		}).Error(formatLogString("Can't save history"))
+	}
+}
+
+func historySaver() {
+	log.WithFields(log.Fields{
+		"file":          config.History.File,
+		"save_interval": config.History.saveInterval.String(),
+	}).Info(formatLogString("Starting periodic save of the history"))
+
+	ticker := time.NewTicker(config.History.saveInterval)
+	for {
+		<-ticker.C
+		saveHistory()
+	}
+}
+
+// addHistory - called after each poll, failed polls are stored as up = 0
+func addHistory(doc JSONDocument, now time.Time) {
+	metrics := getPollMetrics(doc)
+
+	historyLock.Lock()
+	historyStore.add(now.Unix(), metrics)
+	historyLock.Unlock()
+}
+
+func historyExporter(response http.ResponseWriter, request *http.Request) {
+	var payload []byte
+	var err error
+
+	log.WithFields(log.Fields{
+		"method":         request.Method,
+		"url":            request.URL.String(),
+		"protocol":       request.Proto,
+		"host":           request.Host,
+		"remote_address": request.RemoteAddr,
+		"headers":        fmt.Sprintf("%+v\n", request.Header),
+		"client_cert":    getClientIdentity(request),
+	}).Info(formatLogString("HTTP request from client received"))
+
+	response.Header().Add("X-Clacks-Overhead", "GNU Terry Pratchett")
+
+	query := request.URL.Query()
+	var metrics []string
+	for _, m := range query["metric"] {
+		for _, name := range strings.Split(m, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				metrics = append(metrics, name)
+			}
+		}
+	}
+
+	if len(metrics) > maxHistoryMetrics {
+		response.WriteHeader(http.StatusBadRequest)
+		response.Write([]byte(fmt.Sprintf("Too many metrics, at most %d metrics per query\n", maxHistoryMetrics)))
+		return
+	}
+
+	// without metric the available metrics are listed
+	if len(metrics) == 0 {
+		payload, err = json.Marshal(generateHistoryInfo())
+	} else {
+		var result JSONHistory
+
+		result, err = parseHistoryQuery(query.Get("from"), query.Get("to"), query.Get("step"), time.Now())
+		if err != nil {
+			response.WriteHeader(http.StatusBadRequest)
+			response.Write([]byte(err.Error() + "\n"))
+			return
+		}
+
+		historyLock.Lock()
+		oldest, newest := historyStore.bounds()
+		for _, name := range metrics {
+			var values = make([][2]float64, 0)
+			if newest > 0 {
+				values = historyStore.query(name, result.From, result.To, result.Step, oldest, newest)
+			}
+			result.Series = append(result.Series, JSONHistorySeries{
+				Metric: name,
+				Values: values,
+			})
+		}
+		historyLock.Unlock()
+
+		payload, err = json.Marshal(result)
+	}
+
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(payload)
+}
+
+func generateHistoryInfo() JSONHistoryInfo {
+	var result = JSONHistoryInfo{
+		Resolution: config.History.resolution,
+		Retention:  int64(config.History.Retention) * 86400,
+		Metrics:    make([]string, 0),
+	}
+
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	result.Oldest, result.Newest = historyStore.bounds()
+	for name := range historyStore.Values {
+		result.Metrics = append(result.Metrics, name)
+	}
+	sort.Strings(result.Metrics)
+
+	return result
+}
+
+// parseHistoryQuery - defaults are the last hour and a step for at most 1000 points, but at least the resolution
+func parseHistoryQuery(_from string, _to string, _step string, now time.Time) (JSONHistory, error) {
+	var result = JSONHistory{
+		To:     now.Unix(),
+		Series: make([]JSONHistorySeries, 0),
+	}
+	var err error
+
+	if _to != "" {
+		result.To, err = parseHistoryTime(_to)
+		if err != nil {
+			return result, fmt.Errorf("Invalid value for to")
+		}
+	}
+
+	result.From = result.To - 3600
+	if _from != "" {
+		result.From, err = parseHistoryTime(_from)
+		if err != nil {
+			return result, fmt.Errorf("Invalid value for from")
+		}
+	}
+
+	if result.From > result.To {
+		return result, fmt.Errorf("Start of the range (from) is after the end (to)")
+	}
+
+	// there are no values in the future and older values are gone, so the range is limited to the retention
+	if result.To > now.Unix() {
+		result.To = now.Unix()
+		if result.From > result.To {
+			result.From = result.To
+		}
+	}
+
+	oldest := now.Unix() - int64(config.History.Retention)*86400
+	if result.From < oldest {
+		result.From = oldest
+		if result.To < result.From {
+			result.To = result.From
+		}
+	}
+
+	if _step != "" {
+		seconds, err := parseCheckDuration(_step)
+		if err != nil || seconds <= 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+			return result, fmt.Errorf("Invalid step")
+		}
+		// a step longer than the retention returns at most one point anyway
+		if seconds > float64(config.History.Retention)*86400 {
+			seconds = float64(config.History.Retention) * 86400
+		}
+		result.Step = int64(math.Ceil(seconds))
+	} else {
+		result.Step = (result.To - result.From + 999) / 1000
+	}
+
+	if result.Step < config.History.resolution {
+		result.Step = config.History.resolution
+	}
+
+	if (result.To-result.From)/result.Step+1 > maxHistoryPoints {
+		return result, fmt.Errorf("Too many points, increase step or reduce the range (at most %d points)", maxHistoryPoints)
+	}
+
+	return result, nil
+}
+
+// parseHistoryTime - Unix timestamp in seconds or RFC 3339, times before 1970 or after 9999 are rejected
+func parseHistoryTime(s string) (int64, error) {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		if math.IsNaN(seconds) || seconds < 0 || seconds > maxHistoryTime {
+			return 0, fmt.Errorf("Time %s is out of range", s)
+		}
+		return int64(seconds), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, err
+	}
+	if t.Unix() < 0 {
+		return 0, fmt.Errorf("Time %s is out of range", s)
+	}
+
+	return t.Unix(), nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func setupHistoryTest(now time.Time) {
+	config = &Configuration{
+		History: HistoryConfiguration{
+			Retention: 30,
+			enabled:   true,
+		},
+	}
+	config.History.resolution = 60
+	config.History.slots = int64(config.History.Retention) * 86400 / config.History.resolution
+
+	historyStore = newHistoryStore(config.History.resolution, config.History.slots)
+	for t := now.Unix() - 7200; t <= now.Unix(); t += config.History.resolution {
+		historyStore.add(t, map[string]float64{"dns_queries_today": float64(t)})
+	}
+}
+
+// runHistoryQuery - the query must finish in time, it holds the lock which blocks the poller
+func runHistoryQuery(t *testing.T, from string, to string, step string, now time.Time) (JSONHistory, error) {
+	done := make(chan struct{})
+	var result JSONHistory
+	var err error
+
+	go func() {
+		defer close(done)
+
+		result, err = parseHistoryQuery(from, to, step, now)
+		if err != nil {
+			return
+		}
+
+		oldest, newest := historyStore.bounds()
+		result.Series = append(result.Series, JSONHistorySeries{
+			Metric: "dns_queries_today",
+			Values: historyStore.query("dns_queries_today", result.From, result.To, result.Step, oldest, newest),
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Query from=%s to=%s step=%s didn't finish within 2 seconds", from, to, step)
+	}
+
+	return result, err
+}
+
+func TestHistoryQueryFarFuture(t *testing.T) {
+	now := time.Unix(1792368000, 0)
+	setupHistoryTest(now)
+
+	result, err := runHistoryQuery(t, "0", strconv.FormatInt(now.Unix()+1e11, 10), "", now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if result.To != now.Unix() {
+		t.Errorf("to is %d, expected %d", result.To, now.Unix())
+	}
+	if result.From != now.Unix()-30*86400 {
+		t.Errorf("from is %d, expected %d", result.From, now.Unix()-30*86400)
+	}
+	if len(result.Series[0].Values) == 0 {
+		t.Errorf("No values returned")
+	}
+}
+
+func TestHistoryQueryOverflow(t *testing.T) {
+	now := time.Unix(1792368000, 0)
+	setupHistoryTest(now)
+
+	_, err := runHistoryQuery(t, "", "9223372036854774784", "3000", now)
+	if err == nil {
+		t.Errorf("Expected an error for a time out of range")
+	}
+
+	// the outer loop of the query must stop before t + step overflows
+	oldest, newest := historyStore.bounds()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		historyStore.query("dns_queries_today", math.MaxInt64-10000, math.MaxInt64, 3000, oldest, newest)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Query up to the largest time didn't finish within 2 seconds")
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	for _, s := range []string{"NaN", "Inf", "-Inf", "-1", "1e300", "9223372036854774784", "0001-01-01T00:00:00Z"} {
+		_, err := parseHistoryTime(s)
+		if err == nil {
+			t.Errorf("Expected an error for %s", s)
+		}
+	}
+
+	for s, expected := range map[string]int64{
+		"1792368000":           1792368000,
+		"2026-10-19T00:00:00Z": 1792368000,
+	} {
+		value, err := parseHistoryTime(s)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", s, err)
+		}
+		if value != expected {
+			t.Errorf("%s is %d, expected %d", s, value, expected)
+		}
+	}
+}
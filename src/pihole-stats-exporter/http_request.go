@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func httpRequest(_url string, method string, header map[string]string, payload []byte, insecureSSL bool, caFile string, timeout time.Duration) (HTTPResult, error) {
+	var result HTTPResult
+	var transp *http.Transport
+	var err error
+
+	result.URL = _url
+
+	_parsed, err := url.Parse(_url)
+	if err != nil {
+		return result, err
+	}
+
+	transp = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+
+	if _parsed.Scheme == "https" {
+		transp.TLSClientConfig = &tls.Config{}
+		if insecureSSL {
+			transp.TLSClientConfig.InsecureSkipVerify = true
+		}
+
+		if caFile != "" {
+			cadata, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return result, err
+			}
+
+			cacerts := x509.NewCertPool()
+			if !cacerts.AppendCertsFromPEM(cadata) {
+				return result, fmt.Errorf("Can't append CA data to CA pool")
+			}
+
+			transp.TLSClientConfig.RootCAs = cacerts
+		}
+	}
+
+	cl := &http.Client{
+		Transport: transp,
+		Timeout:   timeout,
+	}
+
+	request, err := http.NewRequest(method, _url, bytes.NewReader(payload))
+	if err != nil {
+		return result, err
+	}
+
+	request.Header.Set("User-Agent", userAgent)
+	request.Header.Set("X-Clacks-Overhead", "GNU Terry Pratchett")
+	for key, value := range header {
+		request.Header.Set(key, value)
+	}
+
+	// close TCP session
+	request.Close = true
+
+	response, err := cl.Do(request)
+	if err != nil {
+		return result, err
+	}
+
+	// always consume reply
+	defer func() {
+		ioutil.ReadAll(response.Body)
+		response.Body.Close()
+	}()
+
+	result.Status = response.Status
+	result.StatusCode = response.StatusCode
+	result.Header = response.Header
+	result.Content, err = ioutil.ReadAll(response.Body)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func basicAuthHeader(user string, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
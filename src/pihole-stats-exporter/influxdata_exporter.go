@@ -21,12 +21,13 @@ func influxExporter(response http.ResponseWriter, request *http.Request) {
 		"host":           request.Host,
 		"remote_address": request.RemoteAddr,
 		"headers":        fmt.Sprintf("%+v\n", request.Header),
+		"client_cert":    getClientIdentity(request),
 	}).Info(formatLogString("HTTP request from client received"))
 
 	response.Header().Add("X-Clacks-Overhead", "GNU Terry Pratchett")
 
 	// get raw summary
-	rawsum, err = getPiHoleRawSummary(request)
+	rawsum, err = getPiHoleRawSummary()
 	if err != nil {
 		log.WithFields(log.Fields{
 			"remote_address": request.RemoteAddr,
@@ -41,7 +42,7 @@ func influxExporter(response http.ResponseWriter, request *http.Request) {
 	}
 
 	// get DNS queriey by type
-	qtypes, err = getPiHoleQueryTypes(request)
+	qtypes, err = getPiHoleQueryTypes()
 	if err != nil {
 		log.WithFields(log.Fields{
 			"remote_address": request.RemoteAddr,
@@ -54,58 +55,69 @@ func influxExporter(response http.ResponseWriter, request *http.Request) {
 
 		return
 	}
-	now := time.Now().Unix() * 1e+09
 
-	payload = []byte(fmt.Sprintf(`pihole,type=summary,upstream=%s,type=domains_being_blocked value=%d %d
-pihole,type=summary,upstream=%s,type=dns_queries_today value=%d %d
-pihole,type=summary,upstream=%s,type=ads_blocked_today value=%d %d
-pihole,type=summary,upstream=%s,type=ads_percentage_today value=%f %d
-pihole,type=summary,upstream=%s,type=unique_domains value=%d %d
-pihole,type=summary,upstream=%s,type=queries_forwarded value=%d %d
-pihole,type=summary,upstream=%s,type=queries_cached value=%d %d
-pihole,type=summary,upstream=%s,type=clients_ever_seen value=%d %d
-pihole,type=summary,upstream=%s,type=unique_clients value=%d %d
-pihole,type=summary,upstream=%s,type=dns_queries_all_types value=%d %d
-pihole,type=summary,upstream=%s,type=reply_NODATA value=%d %d
-pihole,type=summary,upstream=%s,type=reply_NXDOMAIN value=%d %d
-pihole,type=summary,upstream=%s,type=reply_CNAME value=%d %d
-pihole,type=summary,upstream=%s,type=reply_IP value=%d %d
-pihole,type=summary,upstream=%s,type=privacy_level value=%d %d
-pihole,type=querytypes,upstream=%s,type=A value=%f %d
-pihole,type=querytypes,upstream=%s,type=AAAA value=%f %d
-pihole,type=querytypes,upstream=%s,type=ANY value=%f %d
-pihole,type=querytypes,upstream=%s,type=SRV value=%f %d
-pihole,type=querytypes,upstream=%s,type=SOA value=%f %d
-pihole,type=querytypes,upstream=%s,type=PTR value=%f %d
-pihole,type=querytypes,upstream=%s,type=TXT value=%f %d
-pihole,type=querytypes,upstream=%s,type=NAPTR value=%f %d
-`,
-		config.PiHole.URL, rawsum.DomainsBeingBlocked, now,
-		config.PiHole.URL, rawsum.DNSQueriesToday, now,
-		config.PiHole.URL, rawsum.AdsBlockedToday, now,
-		config.PiHole.URL, rawsum.AdsPercentageToday, now,
-		config.PiHole.URL, rawsum.UniqueDomains, now,
-		config.PiHole.URL, rawsum.QueriesForwarded, now,
-		config.PiHole.URL, rawsum.QueriesCached, now,
-		config.PiHole.URL, rawsum.ClientsEverSeend, now,
-		config.PiHole.URL, rawsum.UniqueClients, now,
-		config.PiHole.URL, rawsum.DNSQueriesAllTypes, now,
-		config.PiHole.URL, rawsum.ReplyNODATA, now,
-		config.PiHole.URL, rawsum.ReplyNXDOMAIN, now,
-		config.PiHole.URL, rawsum.ReplyCNAME, now,
-		config.PiHole.URL, rawsum.ReplyIP, now,
-		config.PiHole.URL, rawsum.PrivacyLevel, now,
-		config.PiHole.URL, qtypes.Querytypes.A, now,
-		config.PiHole.URL, qtypes.Querytypes.AAAA, now,
-		config.PiHole.URL, qtypes.Querytypes.ANY, now,
-		config.PiHole.URL, qtypes.Querytypes.SRV, now,
-		config.PiHole.URL, qtypes.Querytypes.SOA, now,
-		config.PiHole.URL, qtypes.Querytypes.PTR, now,
-		config.PiHole.URL, qtypes.Querytypes.TXT, now,
-		config.PiHole.URL, qtypes.Querytypes.NAPTR, now,
-	))
+	payload = generateInfluxData(rawsum, qtypes, time.Now().Unix()*1e+09, true)
 	response.Write(payload)
 
 	// discard slice and force gc to free the allocated memory
 	payload = nil
 }
+
+// generateInfluxData - the output of influxdata_path has always grouped the values by an additional type tag
+// (summary or querytypes), the write API rejects the duplicate tag key so groupTags is only set for the endpoint
+func generateInfluxData(rawsum PiHoleRawSummary, qtypes PiHoleQueryTypes, now int64, groupTags bool) []byte {
+	var summary, querytypes string
+	if groupTags {
+		summary = "type=summary,"
+		querytypes = "type=querytypes,"
+	}
+
+	return []byte(fmt.Sprintf(`pihole,%supstream=%s,type=domains_being_blocked value=%d %d
+pihole,%supstream=%s,type=dns_queries_today value=%d %d
+pihole,%supstream=%s,type=ads_blocked_today value=%d %d
+pihole,%supstream=%s,type=ads_percentage_today value=%f %d
+pihole,%supstream=%s,type=unique_domains value=%d %d
+pihole,%supstream=%s,type=queries_forwarded value=%d %d
+pihole,%supstream=%s,type=queries_cached value=%d %d
+pihole,%supstream=%s,type=clients_ever_seen value=%d %d
+pihole,%supstream=%s,type=unique_clients value=%d %d
+pihole,%supstream=%s,type=dns_queries_all_types value=%d %d
+pihole,%supstream=%s,type=reply_NODATA value=%d %d
+pihole,%supstream=%s,type=reply_NXDOMAIN value=%d %d
+pihole,%supstream=%s,type=reply_CNAME value=%d %d
+pihole,%supstream=%s,type=reply_IP value=%d %d
+pihole,%supstream=%s,type=privacy_level value=%d %d
+pihole,%supstream=%s,type=A value=%f %d
+pihole,%supstream=%s,type=AAAA value=%f %d
+pihole,%supstream=%s,type=ANY value=%f %d
+pihole,%supstream=%s,type=SRV value=%f %d
+pihole,%supstream=%s,type=SOA value=%f %d
+pihole,%supstream=%s,type=PTR value=%f %d
+pihole,%supstream=%s,type=TXT value=%f %d
+pihole,%supstream=%s,type=NAPTR value=%f %d
+`,
+		summary, config.PiHole.URL, rawsum.DomainsBeingBlocked, now,
+		summary, config.PiHole.URL, rawsum.DNSQueriesToday, now,
+		summary, config.PiHole.URL, rawsum.AdsBlockedToday, now,
+		summary, config.PiHole.URL, rawsum.AdsPercentageToday, now,
+		summary, config.PiHole.URL, rawsum.UniqueDomains, now,
+		summary, config.PiHole.URL, rawsum.QueriesForwarded, now,
+		summary, config.PiHole.URL, rawsum.QueriesCached, now,
+		summary, config.PiHole.URL, rawsum.ClientsEverSeend, now,
+		summary, config.PiHole.URL, rawsum.UniqueClients, now,
+		summary, config.PiHole.URL, rawsum.DNSQueriesAllTypes, now,
+		summary, config.PiHole.URL, rawsum.ReplyNODATA, now,
+		summary, config.PiHole.URL, rawsum.ReplyNXDOMAIN, now,
+		summary, config.PiHole.URL, rawsum.ReplyCNAME, now,
+		summary, config.PiHole.URL, rawsum.ReplyIP, now,
+		summary, config.PiHole.URL, rawsum.PrivacyLevel, now,
+		querytypes, config.PiHole.URL, qtypes.Querytypes.A, now,
+		querytypes, config.PiHole.URL, qtypes.Querytypes.AAAA, now,
+		querytypes, config.PiHole.URL, qtypes.Querytypes.ANY, now,
+		querytypes, config.PiHole.URL, qtypes.Querytypes.SRV, now,
+		querytypes, config.PiHole.URL, qtypes.Querytypes.SOA, now,
+		querytypes, config.PiHole.URL, qtypes.Querytypes.PTR, now,
+		querytypes, config.PiHole.URL, qtypes.Querytypes.TXT, now,
+		querytypes, config.PiHole.URL, qtypes.Querytypes.NAPTR, now,
+	))
+}
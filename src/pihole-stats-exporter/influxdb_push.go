@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var influxDBStats InfluxDBPushStatistics
+
+func influxDBPusher() {
+	log.WithFields(log.Fields{
+		"influxdb_url": config.InfluxDB.URL,
+		"version":      config.InfluxDB.Version,
+		"interval":     config.InfluxDB.interval.String(),
+		"spool_dir":    config.InfluxDB.SpoolDir,
+	}).Info(formatLogString("Starting push of metrics to InfluxDB"))
+
+	if config.InfluxDB.SpoolDir != "" {
+		err := os.MkdirAll(config.InfluxDB.SpoolDir, 0700)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"spool_dir": config.InfluxDB.SpoolDir,
+				"error":     err.Error(),
+			}).Error(formatLogString("Can't create spool directory, disabling spooling of InfluxDB data"))
+
+			config.InfluxDB.SpoolDir = ""
+		}
+	}
+
+	ticker := time.NewTicker(config.InfluxDB.interval)
+	for {
+		pushInfluxDB()
+		<-ticker.C
+	}
+}
+
+func pushInfluxDB() {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	payload := generateInfluxData(rawsum, qtypes, time.Now().Unix()*1e+09, false)
+
+	// spooled data must be written before the current data to keep the order of the points
+	online := flushInfluxDBSpool()
+
+	for _, batch := range splitInfluxDBBatches(payload, config.InfluxDB.BatchSize) {
+		if !online {
+			spoolInfluxDBBatch(batch)
+			continue
+		}
+
+		retry, err := writeInfluxDBBatch(batch, config.InfluxDB.Retries)
+		if err != nil {
+			if retry {
+				online = false
+				spoolInfluxDBBatch(batch)
+			} else {
+				influxDBStats.lock.Lock()
+				influxDBStats.PointsDropped += countInfluxDBPoints(batch)
+				influxDBStats.lock.Unlock()
+			}
+		}
+	}
+}
+
+// writeInfluxDBBatch - send a batch of line protocol data, returns true if the write should be tried again later
+func writeInfluxDBBatch(batch []byte, retries uint) (bool, error) {
+	var err error
+	var result HTTPResult
+	var payload = batch
+
+	header := map[string]string{
+		"Content-Type": "text/plain; charset=utf-8",
+	}
+
+	auth := buildInfluxDBAuthHeader(config.InfluxDB)
+	if auth != "" {
+		header["Authorization"] = auth
+	}
+
+	if config.InfluxDB.Gzip {
+		payload, err = gzipData(batch)
+		if err != nil {
+			return false, err
+		}
+		header["Content-Encoding"] = "gzip"
+	}
+
+	backoff := time.Second
+	for attempt := uint(0); attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+
+		result, err = httpRequest(config.InfluxDB.writeURL, "POST", header, payload, config.InfluxDB.InsecureSSL, config.InfluxDB.CAFile, config.InfluxDB.timeout)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"influxdb_url": config.InfluxDB.URL,
+				"attempt":      attempt + 1,
+				"error":        err.Error(),
+			}).Error(formatLogString("Can't send data to InfluxDB"))
+
+			continue
+		}
+
+		if result.StatusCode == http.StatusNoContent || result.StatusCode == http.StatusOK {
+			influxDBStats.lock.Lock()
+			influxDBStats.Success++
+			influxDBStats.PointsWritten += countInfluxDBPoints(batch)
+			influxDBStats.LastSuccess = time.Now().Unix()
+			influxDBStats.lock.Unlock()
+
+			return false, nil
+		}
+
+		log.WithFields(log.Fields{
+			"influxdb_url": config.InfluxDB.URL,
+			"attempt":      attempt + 1,
+			"status_code":  result.StatusCode,
+			"status":       result.Status,
+			"response":     strings.TrimSpace(string(result.Content)),
+		}).Error(formatLogString("Unexpected HTTP status from InfluxDB"))
+
+		err = fmt.Errorf("Unexpected HTTP status from InfluxDB: %s", result.Status)
+
+		// client errors (e.g. malformed data, authentication) will not be fixed by sending the data again
+		if result.StatusCode < 500 && result.StatusCode != http.StatusTooManyRequests {
+			influxDBStats.lock.Lock()
+			influxDBStats.Failure++
+			influxDBStats.lock.Unlock()
+
+			return false, err
+		}
+	}
+
+	influxDBStats.lock.Lock()
+	influxDBStats.Failure++
+	influxDBStats.lock.Unlock()
+
+	return true, err
+}
+
+func splitInfluxDBBatches(payload []byte, size uint) [][]byte {
+	var result [][]byte
+	var batch []byte
+	var count uint
+
+	for _, line := range bytes.SplitAfter(payload, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		batch = append(batch, line...)
+		count++
+
+		if count == size {
+			result = append(result, batch)
+			batch = nil
+			count = 0
+		}
+	}
+
+	if count > 0 {
+		result = append(result, batch)
+	}
+
+	return result
+}
+
+func countInfluxDBPoints(batch []byte) uint64 {
+	return uint64(bytes.Count(batch, []byte("\n")))
+}
+
+func buildInfluxDBAuthHeader(cfg InfluxDBConfiguration) string {
+	if cfg.Version == 2 {
+		return "Token " + cfg.Token
+	}
+	if cfg.Username != "" {
+		return basicAuthHeader(cfg.Username, cfg.Password)
+	}
+	return ""
+}
+
+func gzipData(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	gz := gzip.NewWriter(&buffer)
+	_, err := gz.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func listInfluxDBSpool() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(config.InfluxDB.SpoolDir, "*.lp"))
+	if err != nil {
+		return nil, err
+	}
+
+	// file names are zero padded timestamps, so lexical order is chronological order
+	sort.Strings(files)
+	return files, nil
+}
+
+func spoolInfluxDBBatch(batch []byte) {
+	if config.InfluxDB.SpoolDir == "" {
+		influxDBStats.lock.Lock()
+		influxDBStats.PointsDropped += countInfluxDBPoints(batch)
+		influxDBStats.lock.Unlock()
+
+		return
+	}
+
+	spoolFile := filepath.Join(config.InfluxDB.SpoolDir, fmt.Sprintf("%020d.lp", time.Now().UnixNano()))
+
+	// write to a temporary file first, a partially written spool file would be sent on the next flush
+	err := ioutil.WriteFile(spoolFile+".tmp", batch, 0600)
+	if err == nil {
+		err = os.Rename(spoolFile+".tmp", spoolFile)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"spool_file": spoolFile,
+			"error":      err.Error(),
+		}).Error(formatLogString("Can't write InfluxDB data to spool file"))
+
+		os.Remove(spoolFile + ".tmp")
+
+		influxDBStats.lock.Lock()
+		influxDBStats.PointsDropped += countInfluxDBPoints(batch)
+		influxDBStats.lock.Unlock()
+
+		return
+	}
+
+	influxDBStats.lock.Lock()
+	influxDBStats.PointsSpooled += countInfluxDBPoints(batch)
+	influxDBStats.lock.Unlock()
+
+	files, err := listInfluxDBSpool()
+	if err != nil {
+		return
+	}
+
+	// remove oldest data if the spool is full
+	for config.InfluxDB.SpoolMaxFiles > 0 && uint(len(files)) > config.InfluxDB.SpoolMaxFiles {
+		data, _ := ioutil.ReadFile(files[0])
+
+		log.WithFields(log.Fields{
+			"spool_file":      files[0],
+			"spool_max_files": config.InfluxDB.SpoolMaxFiles,
+		}).Warning(formatLogString("InfluxDB spool is full, discarding oldest data"))
+
+		os.Remove(files[0])
+		files = files[1:]
+
+		influxDBStats.lock.Lock()
+		influxDBStats.PointsDropped += countInfluxDBPoints(data)
+		influxDBStats.lock.Unlock()
+	}
+
+	influxDBStats.lock.Lock()
+	influxDBStats.SpoolFiles = uint64(len(files))
+	influxDBStats.lock.Unlock()
+}
+
+// flushInfluxDBSpool - send spooled data to InfluxDB, returns false if InfluxDB is (still) not available
+func flushInfluxDBSpool() bool {
+	if config.InfluxDB.SpoolDir == "" {
+		return true
+	}
+
+	files, err := listInfluxDBSpool()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"spool_dir": config.InfluxDB.SpoolDir,
+			"error":     err.Error(),
+		}).Error(formatLogString("Can't list InfluxDB spool directory"))
+
+		return true
+	}
+
+	defer func() {
+		remaining, _ := listInfluxDBSpool()
+		influxDBStats.lock.Lock()
+		influxDBStats.SpoolFiles = uint64(len(remaining))
+		influxDBStats.lock.Unlock()
+	}()
+
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"spool_file": f,
+				"error":      err.Error(),
+			}).Error(formatLogString("Can't read InfluxDB spool file"))
+
+			continue
+		}
+
+		// don't retry, the spool will be processed again on the next run
+		retry, err := writeInfluxDBBatch(data, 0)
+		if err != nil && retry {
+			return false
+		}
+
+		if err != nil {
+			influxDBStats.lock.Lock()
+			influxDBStats.PointsDropped += countInfluxDBPoints(data)
+			influxDBStats.lock.Unlock()
+		}
+
+		os.Remove(f)
+	}
+
+	return true
+}
+
+func generateInfluxDBPushMetrics() []byte {
+	influxDBStats.lock.Lock()
+	defer influxDBStats.lock.Unlock()
+
+	return []byte(fmt.Sprintf(`#HELP pihole_exporter_influxdb_pushes_total Number of write requests sent to InfluxDB
+#TYPE pihole_exporter_influxdb_pushes_total counter
+pihole_exporter_influxdb_pushes_total{upstream="%s",result="success"} %d
+pihole_exporter_influxdb_pushes_total{upstream="%s",result="failure"} %d
+#HELP pihole_exporter_influxdb_points_total Number of points processed for InfluxDB
+#TYPE pihole_exporter_influxdb_points_total counter
+pihole_exporter_influxdb_points_total{upstream="%s",state="written"} %d
+pihole_exporter_influxdb_points_total{upstream="%s",state="spooled"} %d
+pihole_exporter_influxdb_points_total{upstream="%s",state="dropped"} %d
+#HELP pihole_exporter_influxdb_spool_files Number of files in the InfluxDB spool directory
+#TYPE pihole_exporter_influxdb_spool_files gauge
+pihole_exporter_influxdb_spool_files{upstream="%s"} %d
+#HELP pihole_exporter_influxdb_last_success_timestamp_seconds Time of the last successful write to InfluxDB
+#TYPE pihole_exporter_influxdb_last_success_timestamp_seconds gauge
+pihole_exporter_influxdb_last_success_timestamp_seconds{upstream="%s"} %d
+`,
+		config.PiHole.URL, influxDBStats.Success,
+		config.PiHole.URL, influxDBStats.Failure,
+		config.PiHole.URL, influxDBStats.PointsWritten,
+		config.PiHole.URL, influxDBStats.PointsSpooled,
+		config.PiHole.URL, influxDBStats.PointsDropped,
+		config.PiHole.URL, influxDBStats.SpoolFiles,
+		config.PiHole.URL, influxDBStats.LastSuccess,
+	))
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// version of the JSON document, must be increased on incompatible changes
+const jsonSchemaVersion = 1
+
+func jsonExporter(response http.ResponseWriter, request *http.Request) {
+	var payload []byte
+	var err error
+	var data interface{}
+
+	log.WithFields(log.Fields{
+		"method":         request.Method,
+		"url":            request.URL.String(),
+		"protocol":       request.Proto,
+		"host":           request.Host,
+		"remote_address": request.RemoteAddr,
+		"headers":        fmt.Sprintf("%+v\n", request.Header),
+		"client_cert":    getClientIdentity(request),
+	}).Info(formatLogString("HTTP request from client received"))
+
+	response.Header().Add("X-Clacks-Overhead", "GNU Terry Pratchett")
+	response.Header().Set("Content-Type", "application/json")
+
+	doc := generateJSONDocument()
+	data = doc
+
+	fields := request.URL.Query().Get("fields")
+	if fields != "" {
+		data, err = selectJSONFields(doc, strings.Split(fields, ","))
+		if err != nil {
+			response.WriteHeader(http.StatusBadRequest)
+			payload, _ = json.Marshal(map[string]string{"error": err.Error()})
+			response.Write(payload)
+			return
+		}
+	}
+
+	_, pretty := request.URL.Query()["pretty"]
+	if pretty {
+		payload, err = json.MarshalIndent(data, "", "  ")
+	} else {
+		payload, err = json.Marshal(data)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"remote_address": request.RemoteAddr,
+			"error":          err.Error(),
+		}).Error(formatLogString("Can't encode JSON document"))
+
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if len(doc.Errors) > 0 {
+		response.WriteHeader(http.StatusBadGateway)
+	}
+
+	response.Write(append(payload, '\n'))
+}
+
+// generateJSONDocument - fetch data from the PiHole server, errors are reported in the document
+func generateJSONDocument() JSONDocument {
+	now := time.Now()
+
+	doc := JSONDocument{
+		SchemaVersion: jsonSchemaVersion,
+		Exporter: JSONExporterInfo{
+			Name:    name,
+			Version: version,
+		},
+		Instance: JSONInstanceInfo{
+			Name: config.PiHole.Instance,
+			URL:  config.PiHole.URL,
+		},
+		Timestamp:     now.Format(time.RFC3339),
+		TimestampUnix: now.Unix(),
+		Errors:        make([]JSONError, 0),
+	}
+
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		doc.Errors = append(doc.Errors, JSONError{Request: "summaryRaw", Message: err.Error()})
+	} else {
+		doc.Blocking = &JSONBlockingStatus{
+			Enabled: rawsum.Status == "enabled",
+			Status:  rawsum.Status,
+		}
+
+		doc.Summary = &JSONSummary{
+			DomainsBeingBlocked: rawsum.DomainsBeingBlocked,
+			DNSQueriesToday:     rawsum.DNSQueriesToday,
+			AdsBlockedToday:     rawsum.AdsBlockedToday,
+			AdsRatioToday:       rawsum.AdsPercentageToday / 100.0,
+			UniqueDomains:       rawsum.UniqueDomains,
+			QueriesForwarded:    rawsum.QueriesForwarded,
+			QueriesCached:       rawsum.QueriesCached,
+			ClientsEverSeen:     rawsum.ClientsEverSeend,
+			UniqueClients:       rawsum.UniqueClients,
+			DNSQueriesAllTypes:  rawsum.DNSQueriesAllTypes,
+			PrivacyLevel:        rawsum.PrivacyLevel,
+		}
+
+		doc.Replies = getJSONReplies(rawsum)
+
+		doc.Gravity = &JSONGravity{
+			FileExists:  rawsum.GravityLastUpdated.FileExists,
+			LastUpdated: rawsum.GravityLastUpdated.Absolute,
+		}
+		if rawsum.GravityLastUpdated.Absolute > 0 && uint64(now.Unix()) > rawsum.GravityLastUpdated.Absolute {
+			doc.Gravity.AgeSeconds = uint64(now.Unix()) - rawsum.GravityLastUpdated.Absolute
+		}
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		doc.Errors = append(doc.Errors, JSONError{Request: "getQueryTypes", Message: err.Error()})
+	} else {
+		doc.QueryTypes = getJSONQueryTypes(qtypes)
+	}
+
+	return doc
+}
+
+func getJSONReplies(rawsum PiHoleRawSummary) map[string]uint64 {
+	return map[string]uint64{
+		"NODATA":   rawsum.ReplyNODATA,
+		"NXDOMAIN": rawsum.ReplyNXDOMAIN,
+		"CNAME":    rawsum.ReplyCNAME,
+		"IP":       rawsum.ReplyIP,
+	}
+}
+
+// getJSONQueryTypes - the PiHole server reports percentages, the document uses fractions
+func getJSONQueryTypes(qtypes PiHoleQueryTypes) map[string]float64 {
+	return map[string]float64{
+		"A":     qtypes.Querytypes.A / 100.0,
+		"AAAA":  qtypes.Querytypes.AAAA / 100.0,
+		"ANY":   qtypes.Querytypes.ANY / 100.0,
+		"SRV":   qtypes.Querytypes.SRV / 100.0,
+		"SOA":   qtypes.Querytypes.SOA / 100.0,
+		"PTR":   qtypes.Querytypes.PTR / 100.0,
+		"TXT":   qtypes.Querytypes.TXT / 100.0,
+		"NAPTR": qtypes.Querytypes.NAPTR / 100.0,
+	}
+}
+
+// selectJSONFields - reduce the document to the requested fields, nested fields are separated by a dot
+func selectJSONFields(doc JSONDocument, fields []string) (map[string]interface{}, error) {
+	var full map[string]interface{}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, &full)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"schema_version": full["schema_version"],
+	}
+
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		var src interface{} = full
+		var dst = result
+		path := strings.Split(field, ".")
+
+		for i, element := range path {
+			srcMap, ok := src.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("Unknown field %s", field)
+			}
+
+			value, found := srcMap[element]
+			if !found {
+				return nil, fmt.Errorf("Unknown field %s", field)
+			}
+
+			// no data available, e.g. the PiHole server was not reachable
+			if i == len(path)-1 || value == nil {
+				dst[element] = value
+				break
+			}
+
+			next, ok := dst[element].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				dst[element] = next
+			}
+
+			src = value
+			dst = next
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func lokiSender() {
+	log.WithFields(log.Fields{
+		"loki_url":    config.Loki.URL,
+		"database":    config.QueryLog.Database,
+		"cursor_file": config.Loki.CursorFile,
+		"events":      config.Loki.Events,
+		"interval":    config.Loki.interval.String(),
+	}).Info(formatLogString("Starting streaming of the query log to Loki"))
+
+	ticker := time.NewTicker(config.Loki.interval)
+	for {
+		sendLoki()
+		<-ticker.C
+	}
+}
+
+// sendLoki - send all new queries, the cursor is only moved forward if Loki accepted the data
+func sendLoki() {
+	cursor, err := readQueryLogCursor(config.Loki.CursorFile)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"database":    config.QueryLog.Database,
+			"cursor_file": config.Loki.CursorFile,
+			"error":       err.Error(),
+		}).Error(formatLogString("Can't get cursor of the query log"))
+
+		return
+	}
+
+	for {
+		entries, err := readQueryLog(cursor, config.QueryLog.BatchSize)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"database": config.QueryLog.Database,
+				"cursor":   cursor,
+				"error":    err.Error(),
+			}).Error(formatLogString("Can't read query log"))
+
+			return
+		}
+
+		if len(entries) == 0 {
+			return
+		}
+
+		request := buildLokiPushRequest(entries)
+		if len(request.Streams) > 0 {
+			retry, err := pushLoki(request)
+			if err != nil && retry {
+				return
+			}
+		}
+
+		cursor = entries[len(entries)-1].ID
+		err = writeQueryLogCursor(config.Loki.CursorFile, cursor)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"cursor_file": config.Loki.CursorFile,
+				"cursor":      cursor,
+				"error":       err.Error(),
+			}).Error(formatLogString("Can't write cursor of the query log"))
+
+			return
+		}
+
+		if uint(len(entries)) < config.QueryLog.BatchSize {
+			return
+		}
+	}
+}
+
+// buildLokiPushRequest - stream labels are kept to instance and status to keep the number of streams low
+func buildLokiPushRequest(entries []QueryLogEntry) LokiPushRequest {
+	var result LokiPushRequest
+	var streams = make(map[string]int)
+
+	for _, entry := range entries {
+		var status = "allowed"
+
+		if entry.Blocked {
+			status = "blocked"
+		} else if config.Loki.Events == "blocked" {
+			continue
+		}
+
+		line, err := json.Marshal(LokiLogLine{
+			ID:        entry.ID,
+			Timestamp: entry.Timestamp.UTC().Format(time.RFC3339Nano),
+			Client:    entry.Client,
+			Domain:    entry.Domain,
+			Type:      entry.Type,
+			Status:    entry.Status,
+		})
+		if err != nil {
+			continue
+		}
+
+		idx, found := streams[status]
+		if !found {
+			idx = len(result.Streams)
+			streams[status] = idx
+			result.Streams = append(result.Streams, LokiStream{
+				Stream: map[string]string{
+					"instance": config.PiHole.Instance,
+					"status":   status,
+				},
+			})
+		}
+
+		result.Streams[idx].Values = append(result.Streams[idx].Values, [2]string{
+			strconv.FormatInt(entry.Timestamp.UnixNano(), 10),
+			string(line),
+		})
+	}
+
+	return result
+}
+
+// pushLoki - send data to Loki, returns true if the data should be sent again later
+func pushLoki(request LokiPushRequest) (bool, error) {
+	var result HTTPResult
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return false, err
+	}
+
+	header := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	if config.Loki.Username != "" {
+		header["Authorization"] = basicAuthHeader(config.Loki.Username, config.Loki.Password)
+	}
+
+	if config.Loki.TenantID != "" {
+		header["X-Scope-OrgID"] = config.Loki.TenantID
+	}
+
+	backoff := time.Second
+	for attempt := uint(0); attempt <= config.Loki.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+
+		result, err = httpRequest(config.Loki.pushURL, "POST", header, payload, config.Loki.InsecureSSL, config.Loki.CAFile, config.Loki.timeout)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"loki_url": config.Loki.URL,
+				"attempt":  attempt + 1,
+				"error":    err.Error(),
+			}).Error(formatLogString("Can't send query log to Loki"))
+
+			continue
+		}
+
+		if result.StatusCode == http.StatusNoContent || result.StatusCode == http.StatusOK {
+			return false, nil
+		}
+
+		log.WithFields(log.Fields{
+			"loki_url":    config.Loki.URL,
+			"attempt":     attempt + 1,
+			"status_code": result.StatusCode,
+			"status":      result.Status,
+			"response":    strings.TrimSpace(string(result.Content)),
+		}).Error(formatLogString("Unexpected HTTP status from Loki"))
+
+		err = fmt.Errorf("Unexpected HTTP status from Loki: %s", result.Status)
+
+		// rejected data (e.g. too old or too large) will not be accepted if sent again
+		if result.StatusCode < 500 && result.StatusCode != http.StatusTooManyRequests {
+			log.WithFields(log.Fields{
+				"loki_url": config.Loki.URL,
+			}).Warning(formatLogString("Loki rejected the query log data, skipping data"))
+
+			return false, err
+		}
+	}
+
+	return true, err
+}
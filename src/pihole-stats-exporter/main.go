@@ -21,9 +21,25 @@ func main() {
 	var help = flag.Bool("help", false, "Show help text")
 	var version = flag.Bool("version", false, "Show version information")
 	var configFile = flag.String("config", "", "Path to configuration file")
+	var zabbixLLD = flag.String("zabbix-lld", "", "Print Zabbix low-level discovery data")
+	var execd = flag.Bool("execd", false, "Run as execd plugin for Telegraf")
+	var collectd = flag.Bool("collectd", false, "Run as plugin for the exec plugin of collectd")
 	var logFmt = new(log.TextFormatter)
 	var err error
 
+	// the check subcommand has its own set of options
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "munin" {
+		os.Exit(runMunin(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		os.Exit(runReport(os.Args[2:]))
+	}
+
 	flag.Usage = showUsage
 
 	flag.Parse()
@@ -58,15 +74,173 @@ func main() {
 		}).Fatal(formatLogString("Can't parse configuration file"))
 	}
 
-	if config.Exporter.PrometheusPath == "" && config.Exporter.InfluxDataPath == "" {
+	if *zabbixLLD != "" {
+		err = printZabbixLLD(*zabbixLLD)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"config_file": *configFile,
+				"type":        *zabbixLLD,
+				"error":       err.Error(),
+			}).Fatal(formatLogString("Can't print Zabbix low-level discovery data"))
+		}
+		os.Exit(0)
+	}
+
+	if *execd && *collectd {
+		log.Fatal(formatLogString("Options --execd and --collectd are mutually exclusive"))
+	}
+
+	// in execd and collectd mode the data is written to stdout instead
+	serveHTTP := !*execd && !*collectd && (config.Exporter.PrometheusPath != "" || config.Exporter.InfluxDataPath != "" || config.Exporter.JSONPath != "" || config.Exporter.SSEPath != "")
+
+	if !serveHTTP && !*execd && !*collectd && !config.Textfile.enabled {
 		log.WithFields(log.Fields{
 			"config_file": *configFile,
-		}).Fatal(formatLogString("Neither the path for Prometheus metrics nor for InfluxDB metrics are set"))
+		}).Fatal(formatLogString("Neither the path for Prometheus metrics nor for InfluxDB metrics nor for JSON data nor the textfile output are set"))
 	}
 
-	if config.Exporter.PrometheusPath == "" {
+	// no HTTP server is required if the data is only written for the textfile collector
+	var httpSrv *http.Server
+	if serveHTTP {
+		httpSrv = startHTTPServer(*configFile)
+	}
+
+	// the saved history must be loaded before the first poll is added
+	if config.History.enabled {
+		initHistory()
+
+		if config.History.File != "" {
+			go historySaver()
+		}
+	}
+
+	// the background poll is only required for consumers which are notified on every poll
+	if (serveHTTP && config.Exporter.SSEPath != "") || len(config.Alerts) > 0 || config.History.enabled {
+		go backgroundPoller()
+	}
+
+	if config.InfluxDB.enabled {
+		go influxDBPusher()
+	}
+
+	if config.RemoteWrite.enabled {
+		go remoteWriter()
+	}
+
+	if config.Pushgateway.enabled {
+		go pushgatewayPusher()
+	}
+
+	if config.Graphite.enabled {
+		go graphiteSender()
+	}
+
+	if config.StatsD.enabled {
+		go statsDSender()
+	}
+
+	if config.OTLP.enabled {
+		go otlpSender()
+	}
+
+	if config.MQTT.enabled {
+		go mqttPublisher()
+	}
+
+	if config.Zabbix.enabled {
+		go zabbixSender()
+	}
+
+	if config.Textfile.enabled {
+		go textfileWriter()
+	}
+
+	if config.AgentX.enabled {
+		go agentxSubagent()
+	}
+
+	if config.Loki.enabled {
+		go lokiSender()
+	}
+
+	if config.Syslog.enabled {
+		go syslogSender()
+	}
+
+	if config.Elasticsearch.enabled {
+		go elasticsearchSender()
+	}
+
+	if len(config.Webhooks) > 0 {
+		go eventEngine()
+	}
+
+	if config.Report.enabled {
+		go reportScheduler()
+	}
+
+	// stays open if not running in execd mode
+	execdDone := make(chan bool)
+	if *execd {
+		go runExecd(execdDone)
+	}
+
+	if *collectd {
+		go runCollectd()
+	}
+
+	// listen for signals
+	sigChan := make(chan os.Signal, 1)
+
+	// Listen for SIGINT, SIGKILL and SIGTERM signals
+	signal.Notify(sigChan, os.Interrupt, os.Kill, syscall.SIGTERM)
+
+	select {
+	case sig := <-sigChan:
 		log.WithFields(log.Fields{
 			"config_file":     *configFile,
+			"exporter_url":    config.Exporter.URL,
+			"prometheus_path": config.Exporter.PrometheusPath,
+			"influxdata_path": config.Exporter.InfluxDataPath,
+			"signal":          sig.String(),
+		}).Info(formatLogString("Received termination signal, terminating HTTP server"))
+	case <-execdDone:
+		log.WithFields(log.Fields{
+			"config_file": *configFile,
+		}).Info(formatLogString("Terminating execd mode"))
+	}
+
+	if config.Pushgateway.enabled && config.Pushgateway.DeleteOnShutdown {
+		deletePushgatewayGroup()
+	}
+
+	if config.MQTT.enabled {
+		disconnectMQTT()
+	}
+
+	if config.AgentX.enabled {
+		closeAgentX()
+	}
+
+	if config.History.enabled && config.History.File != "" {
+		saveHistory()
+	}
+
+	if httpSrv != nil {
+		_ctx, _cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer _cancel()
+
+		// This will shutdown the server immediately if no connection is present, otherwise wait for 15 seconds
+		httpSrv.Shutdown(_ctx)
+	}
+
+	os.Exit(0)
+}
+
+func startHTTPServer(configFile string) *http.Server {
+	if config.Exporter.PrometheusPath == "" {
+		log.WithFields(log.Fields{
+			"config_file":     configFile,
 			"prometheus_path": config.Exporter.PrometheusPath,
 			"influxdata_path": config.Exporter.InfluxDataPath,
 		}).Warning(formatLogString("Path for Prometheus metrics is not set, disabling Prometheus metrics"))
@@ -74,7 +248,7 @@ func main() {
 
 	if config.Exporter.InfluxDataPath == "" {
 		log.WithFields(log.Fields{
-			"config_file":     *configFile,
+			"config_file":     configFile,
 			"prometheus_path": config.Exporter.PrometheusPath,
 			"influxdata_path": config.Exporter.InfluxDataPath,
 		}).Warning(formatLogString("Path for InfluxDB metrics is not set, disabling InfluxDB metrics"))
@@ -84,7 +258,7 @@ func main() {
 	_uri, err := url.Parse(config.Exporter.URL)
 	if err != nil {
 		log.WithFields(log.Fields{
-			"config_file":  *configFile,
+			"config_file":  configFile,
 			"exporter_url": config.Exporter.URL,
 			"error":        err.Error(),
 		}).Fatal(formatLogString("Can't parse exporter URL"))
@@ -93,7 +267,7 @@ func main() {
 	// XXX: This should go into validateConfiguration
 	if _uri.Scheme != "http" && _uri.Scheme != "https" {
 		log.WithFields(log.Fields{
-			"config_file":  *configFile,
+			"config_file":  configFile,
 			"exporter_url": config.Exporter.URL,
 		}).Fatal(formatLogString("Invalid or unsupported URL scheme"))
 	}
@@ -109,13 +283,42 @@ func main() {
 		subRouterGet.HandleFunc(config.Exporter.InfluxDataPath, influxExporter)
 	}
 
+	if config.Exporter.JSONPath != "" {
+		subRouterGet.HandleFunc(config.Exporter.JSONPath, jsonExporter)
+	}
+
+	if config.Exporter.SSEPath != "" {
+		subRouterGet.HandleFunc(config.Exporter.SSEPath, sseExporter)
+	}
+
+	if config.Exporter.AlertsPath != "" && len(config.Alerts) > 0 {
+		subRouterGet.HandleFunc(config.Exporter.AlertsPath, alertsExporter)
+	}
+
+	if config.Exporter.HistoryPath != "" && config.History.enabled {
+		subRouterGet.HandleFunc(config.Exporter.HistoryPath, historyExporter)
+	}
+
+	if config.Exporter.DashboardPath != "" {
+		registerDashboard(subRouterGet)
+	}
+
 	log.WithFields(log.Fields{
-		"config_file":     *configFile,
+		"config_file":     configFile,
 		"exporter_url":    config.Exporter.URL,
 		"prometheus_path": config.Exporter.PrometheusPath,
 		"influxdata_path": config.Exporter.InfluxDataPath,
+		"json_path":       config.Exporter.JSONPath,
+		"sse_path":        config.Exporter.SSEPath,
+		"dashboard_path":  config.Exporter.DashboardPath,
+		"authentication":  config.Auth.enabled,
+		"client_auth":     config.Exporter.ClientAuth,
 	}).Info(formatLogString("Starting HTTP listener"))
 
+	if config.Auth.enabled {
+		router.Use(authMiddleware)
+	}
+
 	router.Host(_uri.Host)
 
 	// XXX: Add timeout values to configuration file instead of hardcoding values
@@ -131,7 +334,7 @@ func main() {
 		_tls, err := generateTLSConfiguration(config)
 		if err != nil {
 			log.WithFields(log.Fields{
-				"config_file":  *configFile,
+				"config_file":  configFile,
 				"exporter_url": config.Exporter.URL,
 				"error":        err.Error(),
 			}).Fatal(formatLogString("Can't create TLS context"))
@@ -141,43 +344,27 @@ func main() {
 		httpSrv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0)
 	}
 
-	// start HTTP routine and wait for termination signals to arrive
+	httpSrv.RegisterOnShutdown(func() {
+		close(sseShutdown)
+	})
+
+	// start HTTP routine
 	go func() {
+		var err error
+
 		if _uri.Scheme == "https" {
 			err = httpSrv.ListenAndServeTLS(config.Exporter.SSLCert, config.Exporter.SSLKey)
 		} else {
 			err = httpSrv.ListenAndServe()
 		}
-		if err != nil {
+		if err != nil && err != http.ErrServerClosed {
 			log.WithFields(log.Fields{
-				"config_file":  *configFile,
+				"config_file":  configFile,
 				"exporter_url": config.Exporter.URL,
 				"error":        err.Error(),
 			}).Fatal(formatLogString("Can't start HTTP server"))
 		}
 	}()
 
-	// listen for signals
-	sigChan := make(chan os.Signal, 1)
-
-	// Listen for SIGINT, SIGKILL and SIGTERM signals
-	signal.Notify(sigChan, os.Interrupt, os.Kill, syscall.SIGTERM)
-
-	sig := <-sigChan
-
-	log.WithFields(log.Fields{
-		"config_file":     *configFile,
-		"exporter_url":    config.Exporter.URL,
-		"prometheus_path": config.Exporter.PrometheusPath,
-		"influxdata_path": config.Exporter.InfluxDataPath,
-		"signal":          sig.String(),
-	}).Info(formatLogString("Received termination signal, terminating HTTP server"))
-
-	_ctx, _cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer _cancel()
-
-	// This will shutdown the server immediately if no connection is present, otherwise wait for 15 seconds
-	httpSrv.Shutdown(_ctx)
-
-	os.Exit(0)
+	return httpSrv
 }
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+)
+
+// the client is created by the publisher and disconnected on shutdown
+var mqttLock sync.Mutex
+var mqttClient mqtt.Client
+var mqttInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+
+func mqttPublisher() {
+	log.WithFields(log.Fields{
+		"mqtt_broker":  config.MQTT.Broker,
+		"client_id":    config.MQTT.ClientID,
+		"topic_prefix": config.MQTT.TopicPrefix,
+		"interval":     config.MQTT.interval.String(),
+	}).Info(formatLogString("Starting MQTT output"))
+
+	opts, err := buildMQTTClientOptions()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"mqtt_broker": config.MQTT.Broker,
+			"error":       err.Error(),
+		}).Error(formatLogString("Can't create MQTT client configuration, disabling MQTT output"))
+
+		return
+	}
+
+	client := mqtt.NewClient(opts)
+
+	mqttLock.Lock()
+	mqttClient = client
+	mqttLock.Unlock()
+
+	// the client reconnects automatically after the initial connection has been established
+	for {
+		token := client.Connect()
+		if token.WaitTimeout(config.MQTT.timeout) && token.Error() == nil {
+			break
+		}
+
+		msg := "timeout"
+		if token.Error() != nil {
+			msg = token.Error().Error()
+		}
+		log.WithFields(log.Fields{
+			"mqtt_broker": config.MQTT.Broker,
+			"error":       msg,
+		}).Error(formatLogString("Can't connect to MQTT broker"))
+
+		time.Sleep(config.MQTT.interval)
+	}
+
+	ticker := time.NewTicker(config.MQTT.interval)
+	for {
+		publishMQTT(client)
+		<-ticker.C
+	}
+}
+
+func buildMQTTClientOptions() (*mqtt.ClientOptions, error) {
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(config.MQTT.Broker)
+	opts.SetClientID(config.MQTT.ClientID)
+	opts.SetUsername(config.MQTT.Username)
+	opts.SetPassword(config.MQTT.Password)
+	opts.SetConnectTimeout(config.MQTT.timeout)
+	opts.SetAutoReconnect(true)
+	opts.SetCleanSession(true)
+
+	// the broker marks the exporter as offline if the connection is lost
+	opts.SetWill(mqttAvailabilityTopic(), "offline", byte(config.MQTT.QoS), true)
+
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		log.WithFields(log.Fields{
+			"mqtt_broker": config.MQTT.Broker,
+		}).Info(formatLogString("Connected to MQTT broker"))
+
+		client.Publish(mqttAvailabilityTopic(), byte(config.MQTT.QoS), true, "online")
+
+		if config.MQTT.HomeAssistantDiscovery {
+			publishMQTTDiscovery(client)
+		}
+	})
+
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		log.WithFields(log.Fields{
+			"mqtt_broker": config.MQTT.Broker,
+			"error":       err.Error(),
+		}).Warning(formatLogString("Connection to MQTT broker lost"))
+	})
+
+	if strings.HasPrefix(config.MQTT.Broker, "ssl://") || strings.HasPrefix(config.MQTT.Broker, "tls://") || strings.HasPrefix(config.MQTT.Broker, "wss://") {
+		tlsCfg := &tls.Config{
+			InsecureSkipVerify: config.MQTT.InsecureSSL,
+		}
+
+		if config.MQTT.CAFile != "" {
+			cadata, err := ioutil.ReadFile(config.MQTT.CAFile)
+			if err != nil {
+				return nil, err
+			}
+
+			cacerts := x509.NewCertPool()
+			if !cacerts.AppendCertsFromPEM(cadata) {
+				return nil, fmt.Errorf("Can't append CA data to CA pool")
+			}
+
+			tlsCfg.RootCAs = cacerts
+		}
+
+		opts.SetTLSConfig(tlsCfg)
+	}
+
+	return opts, nil
+}
+
+func publishMQTT(client mqtt.Client) {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	if !client.IsConnectionOpen() {
+		log.WithFields(log.Fields{
+			"mqtt_broker": config.MQTT.Broker,
+		}).Warning(formatLogString("Not connected to MQTT broker, skipping publishing of data"))
+
+		return
+	}
+
+	messages := map[string]string{
+		config.MQTT.TopicPrefix + "/blocking": rawsum.Status,
+	}
+
+	for _, v := range getPiHoleValues(rawsum, qtypes) {
+		messages[mqttValueTopic(v)] = strconv.FormatFloat(v.Value, 'f', -1, 64)
+	}
+
+	for topic, payload := range messages {
+		token := client.Publish(topic, byte(config.MQTT.QoS), config.MQTT.Retain, payload)
+		if !token.WaitTimeout(config.MQTT.timeout) || token.Error() != nil {
+			msg := "timeout"
+			if token.Error() != nil {
+				msg = token.Error().Error()
+			}
+
+			log.WithFields(log.Fields{
+				"mqtt_broker": config.MQTT.Broker,
+				"topic":       topic,
+				"error":       msg,
+			}).Error(formatLogString("Can't publish data to MQTT broker"))
+
+			return
+		}
+	}
+}
+
+// publishMQTTDiscovery - announce sensors to Home Assistant, discovery messages are always retained
+func publishMQTTDiscovery(client mqtt.Client) {
+	var rawsum PiHoleRawSummary
+	var qtypes PiHoleQueryTypes
+
+	nodeID := sanitizeMQTTTopic(config.PiHole.Instance)
+	device := MQTTDiscoveryDevice{
+		Identifiers:  []string{name + "_" + nodeID},
+		Name:         "PiHole " + config.PiHole.Instance,
+		Manufacturer: "Pi-hole",
+		Model:        name,
+		SWVersion:    version,
+	}
+
+	blocking := MQTTDiscoveryConfig{
+		Name:              "Blocking",
+		UniqueID:          nodeID + "_blocking",
+		StateTopic:        config.MQTT.TopicPrefix + "/blocking",
+		AvailabilityTopic: mqttAvailabilityTopic(),
+		PayloadOn:         "enabled",
+		PayloadOff:        "disabled",
+		Icon:              "mdi:pi-hole",
+		Device:            device,
+	}
+	publishMQTTDiscoveryMessage(client, config.MQTT.DiscoveryPrefix+"/binary_sensor/"+nodeID+"/blocking/config", blocking)
+
+	// only the names of the values are required
+	for _, v := range getPiHoleValues(rawsum, qtypes) {
+		objectID := sanitizeMQTTTopic(v.Group + "_" + v.Name)
+		sensor := MQTTDiscoveryConfig{
+			Name:              strings.Replace(v.Group+" "+v.Name, "_", " ", -1),
+			UniqueID:          nodeID + "_" + objectID,
+			StateTopic:        mqttValueTopic(v),
+			AvailabilityTopic: mqttAvailabilityTopic(),
+			StateClass:        "measurement",
+			Device:            device,
+		}
+
+		switch {
+		case v.Group == "querytypes" || v.Name == "ads_percentage_today":
+			sensor.UnitOfMeasurement = "%"
+		case v.Name == "privacy_level":
+			sensor.StateClass = ""
+		case v.Group == "reply" || strings.HasSuffix(v.Name, "_today") || v.Name == "queries_forwarded" || v.Name == "queries_cached" || v.Name == "dns_queries_all_types":
+			// daily counters are reset at midnight
+			sensor.StateClass = "total_increasing"
+		}
+
+		publishMQTTDiscoveryMessage(client, config.MQTT.DiscoveryPrefix+"/sensor/"+nodeID+"/"+objectID+"/config", sensor)
+	}
+}
+
+func publishMQTTDiscoveryMessage(client mqtt.Client, topic string, msg MQTTDiscoveryConfig) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	client.Publish(topic, byte(config.MQTT.QoS), true, payload)
+}
+
+func disconnectMQTT() {
+	mqttLock.Lock()
+	client := mqttClient
+	mqttLock.Unlock()
+
+	if client == nil || !client.IsConnectionOpen() {
+		return
+	}
+
+	// a clean disconnect doesn't trigger the last will, so the state must be published explicitly
+	token := client.Publish(mqttAvailabilityTopic(), byte(config.MQTT.QoS), true, "offline")
+	token.WaitTimeout(config.MQTT.timeout)
+
+	client.Disconnect(250)
+}
+
+func mqttAvailabilityTopic() string {
+	return config.MQTT.TopicPrefix + "/status"
+}
+
+func mqttValueTopic(v PiHoleValue) string {
+	return config.MQTT.TopicPrefix + "/" + v.Group + "/" + v.Name
+}
+
+func sanitizeMQTTTopic(s string) string {
+	return strings.Trim(mqttInvalidChars.ReplaceAllString(s, "_"), "_")
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var muninGraphs = []MuninGraph{
+	{
+		Name:   "pihole_queries",
+		Title:  "DNS queries today",
+		VLabel: "queries",
+		Info:   "Number of DNS queries received, forwarded, answered from cache and blocked since midnight.",
+		Args:   "--base 1000 -l 0",
+		Fields: []MuninField{
+			{Name: "queries", Label: "received", Value: "summary/dns_queries_today"},
+			{Name: "forwarded", Label: "forwarded", Value: "summary/queries_forwarded"},
+			{Name: "cached", Label: "cached", Value: "summary/queries_cached"},
+			{Name: "blocked", Label: "blocked", Value: "summary/ads_blocked_today"},
+		},
+	},
+	{
+		Name:   "pihole_blocks",
+		Title:  "Blocked DNS queries",
+		VLabel: "%",
+		Info:   "Percentage of blocked DNS queries since midnight.",
+		Args:   "--base 1000 -l 0 -u 100",
+		Fields: []MuninField{
+			{Name: "blocked", Label: "blocked", Value: "summary/ads_percentage_today"},
+		},
+	},
+	{
+		Name:   "pihole_replies",
+		Title:  "DNS replies today",
+		VLabel: "replies",
+		Info:   "Number of DNS replies by type since midnight.",
+		Args:   "--base 1000 -l 0",
+		Fields: []MuninField{
+			{Name: "nodata", Label: "NODATA", Value: "reply/NODATA"},
+			{Name: "nxdomain", Label: "NXDOMAIN", Value: "reply/NXDOMAIN"},
+			{Name: "cname", Label: "CNAME", Value: "reply/CNAME"},
+			{Name: "ip", Label: "IP", Value: "reply/IP"},
+		},
+	},
+	{
+		Name:   "pihole_querytypes",
+		Title:  "DNS queries by type",
+		VLabel: "%",
+		Info:   "Percentage of DNS queries by type.",
+		Args:   "--base 1000 -l 0 -u 100 -r",
+		Stack:  true,
+		Fields: []MuninField{
+			{Name: "a", Label: "A", Value: "querytypes/A"},
+			{Name: "aaaa", Label: "AAAA", Value: "querytypes/AAAA"},
+			{Name: "any", Label: "ANY", Value: "querytypes/ANY"},
+			{Name: "srv", Label: "SRV", Value: "querytypes/SRV"},
+			{Name: "soa", Label: "SOA", Value: "querytypes/SOA"},
+			{Name: "ptr", Label: "PTR", Value: "querytypes/PTR"},
+			{Name: "txt", Label: "TXT", Value: "querytypes/TXT"},
+			{Name: "naptr", Label: "NAPTR", Value: "querytypes/NAPTR"},
+		},
+	},
+}
+
+// runMunin - Munin multigraph plugin, called without argument (or with fetch) for the values and with config for the graph definitions
+func runMunin(args []string) int {
+	flags := flag.NewFlagSet("munin", flag.ContinueOnError)
+	configFile := flags.String("config", "", "Path to configuration file")
+
+	flags.SetOutput(os.Stdout)
+	flags.Usage = func() {
+		fmt.Printf(muninHelpText, name)
+	}
+
+	err := flags.Parse(args)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		return 1
+	}
+
+	command := "fetch"
+	if len(flags.Args()) > 1 {
+		fmt.Fprintln(os.Stderr, "Trailing arguments")
+		return 1
+	}
+	if len(flags.Args()) == 1 {
+		command = flags.Arg(0)
+	}
+
+	// munin-node sets MUNIN_CAP_MULTIGRAPH if the master understands multigraph output, like need_multigraph of Munin::Plugin
+	capability := os.Getenv("MUNIN_CAP_MULTIGRAPH")
+	if capability == "" || capability == "0" {
+		if command == "autoconf" {
+			fmt.Println("no (multigraph not supported by munin-node)")
+			return 0
+		}
+
+		fmt.Println("multigraph not supported by munin-node, exiting")
+		return 1
+	}
+
+	if *configFile == "" {
+		fmt.Fprintln(os.Stderr, "Path to configuration file (--config) is mandatory")
+		return 1
+	}
+
+	config, err = parseConfigurationFile(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can't parse configuration file: "+err.Error())
+		return 1
+	}
+
+	switch command {
+	case "autoconf":
+		fmt.Println("yes")
+	case "config":
+		fmt.Print(generateMuninConfig())
+	case "fetch":
+		// Munin logs stderr of the plugins, but the messages would be logged for every run
+		log.SetOutput(ioutil.Discard)
+		fmt.Print(generateMuninValues())
+	default:
+		fmt.Fprintln(os.Stderr, "Unsupported command "+command+", must be autoconf, config or fetch")
+		return 1
+	}
+
+	return 0
+}
+
+func generateMuninConfig() string {
+	var result string
+
+	for _, graph := range muninGraphs {
+		result += "multigraph " + graph.Name + "\n"
+		result += "graph_title PiHole " + graph.Title + " (" + config.PiHole.Instance + ")\n"
+		result += "graph_vlabel " + graph.VLabel + "\n"
+		result += "graph_args " + graph.Args + "\n"
+		result += "graph_category dns\n"
+		result += "graph_info " + graph.Info + "\n"
+
+		for i, field := range graph.Fields {
+			result += field.Name + ".label " + field.Label + "\n"
+			result += field.Name + ".type GAUGE\n"
+			result += field.Name + ".min 0\n"
+
+			if graph.Stack {
+				if i == 0 {
+					result += field.Name + ".draw AREA\n"
+				} else {
+					result += field.Name + ".draw STACK\n"
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// generateMuninValues - unknown values are reported as U if the PiHole server is not reachable
+func generateMuninValues() string {
+	var result string
+	var values = make(map[string]string)
+
+	rawsum, err := getPiHoleRawSummary()
+	if err == nil {
+		qtypes, err := getPiHoleQueryTypes()
+		if err == nil {
+			for _, v := range getPiHoleValues(rawsum, qtypes) {
+				values[v.Group+"/"+v.Name] = strconv.FormatFloat(v.Value, 'f', -1, 64)
+			}
+		}
+	}
+
+	for _, graph := range muninGraphs {
+		result += "multigraph " + graph.Name + "\n"
+
+		for _, field := range graph.Fields {
+			value, found := values[field.Value]
+			if !found {
+				value = "U"
+			}
+			result += field.Name + ".value " + value + "\n"
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OTLPMetric - definition of an OTLP metric derived from a PiHole value
+type OTLPMetric struct {
+	Name        string
+	Description string
+	Unit        string
+	Attribute   string
+	Sum         bool
+	Percentage  bool
+}
+
+// OTLPCounterState - last observation of a cumulative counter, used to detect resets
+type OTLPCounterState struct {
+	Value     float64
+	Timestamp time.Time
+	Start     time.Time
+}
+
+// PiHole counters are reset at midnight, they are exported as monotonic cumulative sums
+var otlpMetrics = map[string]OTLPMetric{
+	"summary/domains_being_blocked": {Name: "pihole.domains_being_blocked", Description: "Number of blocked domains", Unit: "{domain}"},
+	"summary/dns_queries_today":     {Name: "pihole.dns_queries", Description: "Number of DNS queries received today", Unit: "{query}", Sum: true},
+	"summary/ads_blocked_today":     {Name: "pihole.ads_blocked", Description: "Number of requests blackholed today", Unit: "{query}", Sum: true},
+	"summary/ads_percentage_today":  {Name: "pihole.ads_ratio", Description: "Ratio of blackholed requests", Unit: "1", Percentage: true},
+	"summary/unique_domains":        {Name: "pihole.unique_domains", Description: "Unique domains seen today", Unit: "{domain}", Sum: true},
+	"summary/queries_forwarded":     {Name: "pihole.queries_forwarded", Description: "Number of DNS requests forwarded today", Unit: "{query}", Sum: true},
+	"summary/queries_cached":        {Name: "pihole.queries_cached", Description: "Number of DNS requests answered from cache today", Unit: "{query}", Sum: true},
+	"summary/clients_ever_seen":     {Name: "pihole.clients_ever_seen", Description: "Number of clients ever seen", Unit: "{client}"},
+	"summary/unique_clients":        {Name: "pihole.unique_clients", Description: "Number of unique clients", Unit: "{client}"},
+	"summary/dns_queries_all_types": {Name: "pihole.dns_queries_all_types", Description: "Number of DNS queries of all types today", Unit: "{query}", Sum: true},
+	"summary/privacy_level":         {Name: "pihole.privacy_level", Description: "PiHole privacy level", Unit: "1"},
+	"reply":                         {Name: "pihole.replies", Description: "DNS replies by type today", Unit: "{reply}", Attribute: "reply", Sum: true},
+	"querytypes":                    {Name: "pihole.query_types", Description: "Ratio of DNS type requested from clients", Unit: "1", Attribute: "type", Percentage: true},
+}
+
+var otlpCounters = make(map[string]OTLPCounterState)
+
+func otlpSender() {
+	log.WithFields(log.Fields{
+		"otlp_url": config.OTLP.URL,
+		"interval": config.OTLP.interval.String(),
+	}).Info(formatLogString("Starting OTLP metric export"))
+
+	ticker := time.NewTicker(config.OTLP.interval)
+	for {
+		sendOTLP()
+		<-ticker.C
+	}
+}
+
+func sendOTLP() {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	payload := encodeOTLPRequest(getPiHoleValues(rawsum, qtypes), time.Now())
+
+	header := map[string]string{
+		"Content-Type": "application/x-protobuf",
+	}
+	for key, value := range config.OTLP.headers {
+		header[key] = value
+	}
+
+	if config.OTLP.Gzip {
+		payload, err = gzipData(payload)
+		if err != nil {
+			return
+		}
+		header["Content-Encoding"] = "gzip"
+	}
+
+	result, err := httpRequest(config.OTLP.URL, "POST", header, payload, config.OTLP.InsecureSSL, config.OTLP.CAFile, config.OTLP.timeout)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"otlp_url": config.OTLP.URL,
+			"error":    err.Error(),
+		}).Error(formatLogString("Can't send data to OTLP receiver"))
+
+		return
+	}
+
+	if result.StatusCode < 200 || result.StatusCode >= 300 {
+		log.WithFields(log.Fields{
+			"otlp_url":    config.OTLP.URL,
+			"status_code": result.StatusCode,
+			"status":      result.Status,
+		}).Error(formatLogString("Unexpected HTTP status from OTLP receiver"))
+	}
+}
+
+// encodeOTLPRequest - encode values as ExportMetricsServiceRequest
+func encodeOTLPRequest(values []PiHoleValue, now time.Time) []byte {
+	var metrics = make(map[string][]byte)
+	var order []string
+
+	for _, v := range values {
+		key := v.Group + "/" + v.Name
+		metric, found := otlpMetrics[key]
+		if !found {
+			key = v.Group
+			metric, found = otlpMetrics[key]
+			if !found {
+				continue
+			}
+		}
+
+		value := v.Value
+		if metric.Percentage {
+			value /= 100.0
+		}
+
+		var point []byte
+		if metric.Attribute != "" {
+			point = protobufAppendBytes(point, 7, encodeOTLPKeyValue(metric.Attribute, v.Name))
+		}
+		if metric.Sum {
+			point = protobufAppendFixed64(point, 2, uint64(getOTLPStartTime(metric.Name+"/"+v.Name, value, now).UnixNano()))
+		}
+		point = protobufAppendFixed64(point, 3, uint64(now.UnixNano()))
+		point = protobufAppendDouble(point, 4, value)
+
+		if _, found := metrics[metric.Name]; !found {
+			order = append(order, key)
+		}
+		metrics[metric.Name] = protobufAppendBytes(metrics[metric.Name], 1, point)
+	}
+
+	var scope []byte
+	var instrumentation []byte
+	instrumentation = protobufAppendString(instrumentation, 1, name)
+	instrumentation = protobufAppendString(instrumentation, 2, version)
+	scope = protobufAppendBytes(scope, 1, instrumentation)
+
+	for _, key := range order {
+		metric := otlpMetrics[key]
+
+		var data = metrics[metric.Name]
+		var encoded []byte
+		encoded = protobufAppendString(encoded, 1, metric.Name)
+		encoded = protobufAppendString(encoded, 2, metric.Description)
+		encoded = protobufAppendString(encoded, 3, metric.Unit)
+
+		if metric.Sum {
+			// AGGREGATION_TEMPORALITY_CUMULATIVE = 2
+			data = protobufAppendUint64(data, 2, 2)
+			data = protobufAppendBool(data, 3, true)
+			encoded = protobufAppendBytes(encoded, 7, data)
+		} else {
+			encoded = protobufAppendBytes(encoded, 5, data)
+		}
+
+		scope = protobufAppendBytes(scope, 2, encoded)
+	}
+
+	var resource []byte
+	for _, attr := range getOTLPResourceAttributes() {
+		resource = protobufAppendBytes(resource, 1, attr)
+	}
+
+	var resourceMetrics []byte
+	resourceMetrics = protobufAppendBytes(resourceMetrics, 1, resource)
+	resourceMetrics = protobufAppendBytes(resourceMetrics, 2, scope)
+
+	return protobufAppendBytes(nil, 1, resourceMetrics)
+}
+
+func getOTLPResourceAttributes() [][]byte {
+	var result [][]byte
+
+	result = append(result, encodeOTLPKeyValue("service.name", name))
+	result = append(result, encodeOTLPKeyValue("service.version", version))
+	result = append(result, encodeOTLPKeyValue("service.instance.id", config.PiHole.Instance))
+
+	hostname, err := os.Hostname()
+	if err == nil {
+		result = append(result, encodeOTLPKeyValue("host.name", hostname))
+	}
+
+	result = append(result, encodeOTLPKeyValue("pihole.url", config.PiHole.URL))
+
+	return result
+}
+
+func encodeOTLPKeyValue(key string, value string) []byte {
+	var result []byte
+	var anyValue []byte
+
+	anyValue = protobufAppendString(anyValue, 1, value)
+	result = protobufAppendString(result, 1, key)
+	result = protobufAppendBytes(result, 2, anyValue)
+
+	return result
+}
+
+// getOTLPStartTime - start of the current counter series, the last midnight or the last detected reset (e.g. restart of FTL)
+func getOTLPStartTime(key string, value float64, now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	state, found := otlpCounters[key]
+	if !found || state.Start.Before(midnight) {
+		state.Start = midnight
+	}
+
+	// a decreasing counter was reset between the last and the current observation
+	if found && value < state.Value && !state.Timestamp.Before(midnight) {
+		state.Start = state.Timestamp
+	}
+
+	state.Value = value
+	state.Timestamp = now
+	otlpCounters[key] = state
+
+	return state.Start
+}
@@ -2,23 +2,152 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
+	"golang.org/x/crypto/bcrypt"
 	ini "gopkg.in/ini.v1"
 )
 
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+var zabbixKeyPrefixRegexp = regexp.MustCompile(`^[a-zA-Z0-9_\-.]+$`)
+var elasticsearchIndexRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_\-]*$`)
+
 func parseConfigurationFile(f string) (*Configuration, error) {
 	var err error
 
 	config := Configuration{
 		Exporter: ExporterConfiguration{
-			URL:            defaultExporterURL,
-			PrometheusPath: defaultPrometheusPath,
-			InfluxDataPath: defaultInfluxDataPath,
+			URL:               defaultExporterURL,
+			PrometheusPath:    defaultPrometheusPath,
+			InfluxDataPath:    defaultInfluxDataPath,
+			SSEHeartbeat:      defaultSSEHeartbeat,
+			SSEMaxSubscribers: defaultSSEMaxSubscribers,
+			PollInterval:      defaultPollInterval,
+			AlertsPath:        defaultAlertsPath,
+			HistoryPath:       defaultHistoryPath,
 		},
 		PiHole: PiHoleConfiguration{
 			Timeout: 15,
 		},
+		InfluxDB: InfluxDBConfiguration{
+			Version:       defaultInfluxDBVersion,
+			Interval:      defaultInfluxDBInterval,
+			BatchSize:     defaultInfluxDBBatchSize,
+			Retries:       defaultInfluxDBRetries,
+			Timeout:       defaultInfluxDBTimeout,
+			SpoolMaxFiles: defaultInfluxDBSpoolMaxFiles,
+		},
+		RemoteWrite: RemoteWriteConfiguration{
+			Interval:   defaultRemoteWriteInterval,
+			Timeout:    defaultRemoteWriteTimeout,
+			QueueSize:  defaultRemoteWriteQueueSize,
+			MinBackoff: defaultRemoteWriteMinBackoff,
+			MaxBackoff: defaultRemoteWriteMaxBackoff,
+		},
+		Pushgateway: PushgatewayConfiguration{
+			Job:              defaultPushgatewayJob,
+			Interval:         defaultPushgatewayInterval,
+			Timeout:          defaultPushgatewayTimeout,
+			DeleteOnShutdown: true,
+		},
+		Graphite: GraphiteConfiguration{
+			Protocol:     defaultGraphiteProtocol,
+			PathTemplate: defaultGraphitePathTemplate,
+			Interval:     defaultGraphiteInterval,
+			Timeout:      defaultGraphiteTimeout,
+		},
+		StatsD: StatsDConfiguration{
+			Protocol: defaultStatsDProtocol,
+			MTU:      defaultStatsDMTU,
+			Interval: defaultStatsDInterval,
+		},
+		OTLP: OTLPConfiguration{
+			Interval: defaultOTLPInterval,
+			Timeout:  defaultOTLPTimeout,
+		},
+		MQTT: MQTTConfiguration{
+			QoS:             defaultMQTTQoS,
+			Interval:        defaultMQTTInterval,
+			Timeout:         defaultMQTTTimeout,
+			DiscoveryPrefix: defaultMQTTDiscoveryPrefix,
+		},
+		Zabbix: ZabbixConfiguration{
+			KeyPrefix: defaultZabbixKeyPrefix,
+			Interval:  defaultZabbixInterval,
+			Timeout:   defaultZabbixTimeout,
+		},
+		Textfile: TextfileConfiguration{
+			Interval: defaultTextfileInterval,
+		},
+		AgentX: AgentXConfiguration{
+			Interval: defaultAgentXInterval,
+			Timeout:  defaultAgentXTimeout,
+			BaseOID:  defaultAgentXBaseOID,
+		},
+		QueryLog: QueryLogConfiguration{
+			BatchSize: defaultQueryLogBatchSize,
+		},
+		Loki: LokiConfiguration{
+			Events:   defaultLokiEvents,
+			Interval: defaultLokiInterval,
+			Timeout:  defaultLokiTimeout,
+			Retries:  defaultLokiRetries,
+		},
+		Syslog: SyslogConfiguration{
+			Protocol: defaultSyslogProtocol,
+			Framing:  defaultSyslogFraming,
+			Format:   defaultSyslogFormat,
+			Facility: defaultSyslogFacility,
+			AppName:  name,
+			Events:   defaultSyslogEvents,
+			Interval: defaultSyslogInterval,
+			Timeout:  defaultSyslogTimeout,
+		},
+		Elasticsearch: ElasticsearchConfiguration{
+			StatsIndex:      defaultElasticsearchStatsIndex,
+			QueriesIndex:    defaultElasticsearchQueriesIndex,
+			IndexRotation:   defaultElasticsearchIndexRotation,
+			InstallTemplate: true,
+			Snapshots:       true,
+			Events:          defaultElasticsearchEvents,
+			Interval:        defaultElasticsearchInterval,
+			Timeout:         defaultElasticsearchTimeout,
+			Retries:         defaultElasticsearchRetries,
+			MaxBackoff:      defaultElasticsearchMaxBackoff,
+		},
+		Events: EventsConfiguration{
+			Interval:      defaultEventsInterval,
+			GravityMaxAge: defaultEventsGravityMaxAge,
+			CheckUpdates:  true,
+		},
+		Alertmanager: AlertmanagerConfiguration{
+			Interval: defaultAlertmanagerInterval,
+			Timeout:  defaultAlertmanagerTimeout,
+		},
+		Auth: AuthConfiguration{
+			Realm: defaultAuthRealm,
+		},
+		History: HistoryConfiguration{
+			Retention:    defaultHistoryRetention,
+			Resolution:   defaultHistoryResolution,
+			SaveInterval: defaultHistorySaveInterval,
+		},
+		Report: ReportConfiguration{
+			Period:  defaultReportPeriod,
+			Top:     defaultReportTop,
+			Subject: defaultReportSubject,
+			SMTPTLS: defaultReportSMTPTLS,
+			Timeout: defaultReportTimeout,
+		},
 	}
 
 	cfg, err := ini.Load(f)
@@ -44,13 +173,517 @@ func parseConfigurationFile(f string) (*Configuration, error) {
 		return nil, err
 	}
 
+	// MapTo keeps the default for empty values, but an empty path disables the output
+	for key, path := range map[string]*string{
+		"prometheus_path": &config.Exporter.PrometheusPath,
+		"influxdata_path": &config.Exporter.InfluxDataPath,
+	} {
+		if exporter.HasKey(key) && strings.TrimSpace(exporter.Key(key).String()) == "" {
+			*path = ""
+		}
+	}
+
+	// a CA for client certificates enables the verification of client certificates
+	if config.Exporter.ClientAuth == "" {
+		config.Exporter.ClientAuth = "none"
+		if config.Exporter.ClientCAFile != "" {
+			config.Exporter.ClientAuth = "require-and-verify"
+		}
+	}
+
+	if cfg.HasSection("influxdb") {
+		influxdb, err := cfg.GetSection("influxdb")
+		if err != nil {
+			return nil, err
+		}
+		err = influxdb.MapTo(&config.InfluxDB)
+		if err != nil {
+			return nil, err
+		}
+		config.InfluxDB.enabled = config.InfluxDB.URL != ""
+	}
+
+	if cfg.HasSection("remote_write") {
+		remoteWrite, err := cfg.GetSection("remote_write")
+		if err != nil {
+			return nil, err
+		}
+		err = remoteWrite.MapTo(&config.RemoteWrite)
+		if err != nil {
+			return nil, err
+		}
+		config.RemoteWrite.enabled = config.RemoteWrite.URL != ""
+
+		config.RemoteWrite.externalLabels, err = parseLabelList(config.RemoteWrite.ExternalLabels)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.RemoteWrite.BearerTokenFile != "" {
+			token, err := ioutil.ReadFile(config.RemoteWrite.BearerTokenFile)
+			if err != nil {
+				return nil, err
+			}
+			config.RemoteWrite.BearerToken = strings.TrimSpace(string(token))
+		}
+	}
+
+	if cfg.HasSection("pushgateway") {
+		pushgateway, err := cfg.GetSection("pushgateway")
+		if err != nil {
+			return nil, err
+		}
+		err = pushgateway.MapTo(&config.Pushgateway)
+		if err != nil {
+			return nil, err
+		}
+		config.Pushgateway.enabled = config.Pushgateway.URL != ""
+	}
+
+	if cfg.HasSection("graphite") {
+		graphite, err := cfg.GetSection("graphite")
+		if err != nil {
+			return nil, err
+		}
+		err = graphite.MapTo(&config.Graphite)
+		if err != nil {
+			return nil, err
+		}
+		config.Graphite.enabled = config.Graphite.Address != ""
+	}
+
+	if cfg.HasSection("statsd") {
+		statsd, err := cfg.GetSection("statsd")
+		if err != nil {
+			return nil, err
+		}
+		err = statsd.MapTo(&config.StatsD)
+		if err != nil {
+			return nil, err
+		}
+		config.StatsD.enabled = config.StatsD.Address != ""
+
+		config.StatsD.tags, err = parseLabelList(config.StatsD.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.HasSection("otlp") {
+		otlp, err := cfg.GetSection("otlp")
+		if err != nil {
+			return nil, err
+		}
+		err = otlp.MapTo(&config.OTLP)
+		if err != nil {
+			return nil, err
+		}
+		config.OTLP.enabled = config.OTLP.URL != ""
+
+		config.OTLP.headers, err = parseHeaderList(config.OTLP.Headers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.HasSection("mqtt") {
+		mqtt, err := cfg.GetSection("mqtt")
+		if err != nil {
+			return nil, err
+		}
+		err = mqtt.MapTo(&config.MQTT)
+		if err != nil {
+			return nil, err
+		}
+		config.MQTT.enabled = config.MQTT.Broker != ""
+	}
+
+	if cfg.HasSection("zabbix") {
+		zabbix, err := cfg.GetSection("zabbix")
+		if err != nil {
+			return nil, err
+		}
+		err = zabbix.MapTo(&config.Zabbix)
+		if err != nil {
+			return nil, err
+		}
+		config.Zabbix.enabled = config.Zabbix.Server != ""
+	}
+
+	if cfg.HasSection("textfile") {
+		textfile, err := cfg.GetSection("textfile")
+		if err != nil {
+			return nil, err
+		}
+		err = textfile.MapTo(&config.Textfile)
+		if err != nil {
+			return nil, err
+		}
+		config.Textfile.enabled = config.Textfile.Path != ""
+	}
+
+	if cfg.HasSection("agentx") {
+		agentx, err := cfg.GetSection("agentx")
+		if err != nil {
+			return nil, err
+		}
+		err = agentx.MapTo(&config.AgentX)
+		if err != nil {
+			return nil, err
+		}
+		config.AgentX.enabled = config.AgentX.Master != ""
+	}
+
+	if cfg.HasSection("querylog") {
+		querylog, err := cfg.GetSection("querylog")
+		if err != nil {
+			return nil, err
+		}
+		err = querylog.MapTo(&config.QueryLog)
+		if err != nil {
+			return nil, err
+		}
+		config.QueryLog.enabled = config.QueryLog.Database != ""
+	}
+
+	if cfg.HasSection("loki") {
+		loki, err := cfg.GetSection("loki")
+		if err != nil {
+			return nil, err
+		}
+		err = loki.MapTo(&config.Loki)
+		if err != nil {
+			return nil, err
+		}
+		config.Loki.enabled = config.Loki.URL != ""
+	}
+
+	if cfg.HasSection("syslog") {
+		syslog, err := cfg.GetSection("syslog")
+		if err != nil {
+			return nil, err
+		}
+		err = syslog.MapTo(&config.Syslog)
+		if err != nil {
+			return nil, err
+		}
+		config.Syslog.enabled = config.Syslog.Server != ""
+	}
+
+	if cfg.HasSection("elasticsearch") {
+		elasticsearch, err := cfg.GetSection("elasticsearch")
+		if err != nil {
+			return nil, err
+		}
+		err = elasticsearch.MapTo(&config.Elasticsearch)
+		if err != nil {
+			return nil, err
+		}
+		config.Elasticsearch.enabled = config.Elasticsearch.URL != ""
+	}
+
+	if cfg.HasSection("events") {
+		events, err := cfg.GetSection("events")
+		if err != nil {
+			return nil, err
+		}
+		err = events.MapTo(&config.Events)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// webhooks are named sections, e.g. [webhook "slack"]
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), "webhook ") {
+			continue
+		}
+
+		hook := WebhookConfiguration{
+			ContentType: defaultWebhookContentType,
+			Timeout:     defaultWebhookTimeout,
+			Retries:     defaultWebhookRetries,
+			name:        strings.Trim(strings.TrimSpace(strings.TrimPrefix(section.Name(), "webhook ")), `"`),
+		}
+		err = section.MapTo(&hook)
+		if err != nil {
+			return nil, err
+		}
+		config.Webhooks = append(config.Webhooks, hook)
+	}
+
+	// alert rules are named sections, e.g. [alert "blocking_disabled"]
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), "alert ") {
+			continue
+		}
+
+		rule := AlertConfiguration{
+			name: strings.Trim(strings.TrimSpace(strings.TrimPrefix(section.Name(), "alert ")), `"`),
+		}
+		err = section.MapTo(&rule)
+		if err != nil {
+			return nil, err
+		}
+		config.Alerts = append(config.Alerts, rule)
+	}
+
+	if cfg.HasSection("alertmanager") {
+		alertmanager, err := cfg.GetSection("alertmanager")
+		if err != nil {
+			return nil, err
+		}
+		err = alertmanager.MapTo(&config.Alertmanager)
+		if err != nil {
+			return nil, err
+		}
+		config.Alertmanager.enabled = config.Alertmanager.URL != ""
+	}
+
+	// all parameters of the history have defaults, the section enables it
+	if cfg.HasSection("history") {
+		history, err := cfg.GetSection("history")
+		if err != nil {
+			return nil, err
+		}
+		err = history.MapTo(&config.History)
+		if err != nil {
+			return nil, err
+		}
+		config.History.enabled = true
+	}
+
+	if cfg.HasSection("report") {
+		report, err := cfg.GetSection("report")
+		if err != nil {
+			return nil, err
+		}
+		err = report.MapTo(&config.Report)
+		if err != nil {
+			return nil, err
+		}
+		config.Report.enabled = config.Report.SMTPServer != ""
+	}
+
+	if cfg.HasSection("collectd") {
+		collectd, err := cfg.GetSection("collectd")
+		if err != nil {
+			return nil, err
+		}
+		err = collectd.MapTo(&config.Collectd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.HasSection("auth") {
+		auth, err := cfg.GetSection("auth")
+		if err != nil {
+			return nil, err
+		}
+		err = auth.MapTo(&config.Auth)
+		if err != nil {
+			return nil, err
+		}
+		config.Auth.enabled = config.Auth.UsersFile != "" || config.Auth.TokensFile != ""
+
+		// by default all methods with credentials are accepted
+		if config.Auth.Methods == "" {
+			var methods []string
+			if config.Auth.UsersFile != "" {
+				methods = append(methods, "basic")
+			}
+			if config.Auth.TokensFile != "" {
+				methods = append(methods, "bearer")
+			}
+			config.Auth.Methods = strings.Join(methods, ",")
+		}
+	}
+
+	// policies of paths are named sections, e.g. [auth_path "/dashboard"]
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), "auth_path ") {
+			continue
+		}
+
+		policy := AuthPathConfiguration{
+			path: strings.Trim(strings.TrimSpace(strings.TrimPrefix(section.Name(), "auth_path ")), `"`),
+		}
+		err = section.MapTo(&policy)
+		if err != nil {
+			return nil, err
+		}
+		config.AuthPaths = append(config.AuthPaths, policy)
+	}
+
 	err = validateConfiguration(config)
 	if err != nil {
 		return nil, err
 	}
 
+	// use host part of the PiHole URL as default instance name
+	if config.PiHole.Instance == "" {
+		_url, _ := url.Parse(config.PiHole.URL)
+		config.PiHole.Instance = _url.Host
+	}
+
 	config.PiHole.timeout = time.Duration(config.PiHole.Timeout) * time.Second
 
+	config.Exporter.sseHeartbeat = time.Duration(config.Exporter.SSEHeartbeat) * time.Second
+	config.Exporter.pollInterval = time.Duration(config.Exporter.PollInterval) * time.Second
+	config.Exporter.clientAllowedNames = make(map[string]bool)
+	for _, n := range strings.Split(config.Exporter.ClientAllowedNames, ",") {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n != "" {
+			config.Exporter.clientAllowedNames[n] = true
+		}
+	}
+
+	config.InfluxDB.interval = time.Duration(config.InfluxDB.Interval) * time.Second
+	config.InfluxDB.timeout = time.Duration(config.InfluxDB.Timeout) * time.Second
+	config.InfluxDB.writeURL = buildInfluxDBWriteURL(config.InfluxDB)
+
+	config.RemoteWrite.interval = time.Duration(config.RemoteWrite.Interval) * time.Second
+	config.RemoteWrite.timeout = time.Duration(config.RemoteWrite.Timeout) * time.Second
+	config.RemoteWrite.minBackoff = time.Duration(config.RemoteWrite.MinBackoff) * time.Second
+	config.RemoteWrite.maxBackoff = time.Duration(config.RemoteWrite.MaxBackoff) * time.Second
+
+	config.Graphite.interval = time.Duration(config.Graphite.Interval) * time.Second
+	config.Graphite.timeout = time.Duration(config.Graphite.Timeout) * time.Second
+
+	config.StatsD.interval = time.Duration(config.StatsD.Interval) * time.Second
+
+	config.OTLP.interval = time.Duration(config.OTLP.Interval) * time.Second
+	config.OTLP.timeout = time.Duration(config.OTLP.Timeout) * time.Second
+
+	config.MQTT.interval = time.Duration(config.MQTT.Interval) * time.Second
+	config.MQTT.timeout = time.Duration(config.MQTT.Timeout) * time.Second
+	if config.MQTT.TopicPrefix == "" {
+		config.MQTT.TopicPrefix = "pihole/" + sanitizeMQTTTopic(config.PiHole.Instance)
+	}
+	if config.MQTT.ClientID == "" {
+		config.MQTT.ClientID = name + "-" + sanitizeMQTTTopic(config.PiHole.Instance)
+	}
+
+	config.Zabbix.interval = time.Duration(config.Zabbix.Interval) * time.Second
+	config.Zabbix.timeout = time.Duration(config.Zabbix.Timeout) * time.Second
+	if config.Zabbix.Host == "" {
+		config.Zabbix.Host = config.PiHole.Instance
+	}
+	// use the default port of the Zabbix trapper if no port was given
+	config.Zabbix.address = config.Zabbix.Server
+	if _, _, err := net.SplitHostPort(config.Zabbix.Server); err != nil {
+		config.Zabbix.address = net.JoinHostPort(config.Zabbix.Server, defaultZabbixPort)
+	}
+
+	config.Textfile.interval = time.Duration(config.Textfile.Interval) * time.Second
+
+	config.AgentX.interval = time.Duration(config.AgentX.Interval) * time.Second
+	config.AgentX.timeout = time.Duration(config.AgentX.Timeout) * time.Second
+	config.AgentX.network, config.AgentX.address = parseAgentXMaster(config.AgentX.Master)
+	config.AgentX.baseOID, _ = parseAgentXOID(config.AgentX.BaseOID)
+
+	config.Loki.interval = time.Duration(config.Loki.Interval) * time.Second
+	config.Loki.timeout = time.Duration(config.Loki.Timeout) * time.Second
+	config.Loki.pushURL = strings.TrimRight(config.Loki.URL, "/") + "/loki/api/v1/push"
+
+	config.Syslog.interval = time.Duration(config.Syslog.Interval) * time.Second
+	config.Syslog.timeout = time.Duration(config.Syslog.Timeout) * time.Second
+	config.Syslog.facility = syslogFacilities[config.Syslog.Facility]
+	if config.Syslog.Hostname == "" {
+		config.Syslog.Hostname = config.PiHole.Instance
+	}
+	config.Syslog.Hostname = sanitizeSyslogHeaderField(config.Syslog.Hostname, 255)
+	config.Syslog.AppName = sanitizeSyslogHeaderField(config.Syslog.AppName, 48)
+	// use the default port for syslog (RFC 5426, RFC 6587) or syslog over TLS (RFC 5425) if no port was given
+	config.Syslog.address = config.Syslog.Server
+	if _, _, err := net.SplitHostPort(config.Syslog.Server); err != nil {
+		if config.Syslog.Protocol == "tls" {
+			config.Syslog.address = net.JoinHostPort(config.Syslog.Server, defaultSyslogTLSPort)
+		} else {
+			config.Syslog.address = net.JoinHostPort(config.Syslog.Server, defaultSyslogPort)
+		}
+	}
+	config.Syslog.clientNets, config.Syslog.clientNames = parseSyslogClients(config.Syslog.Clients)
+	for _, d := range strings.Split(config.Syslog.Domains, ",") {
+		d = strings.ToLower(strings.Trim(strings.TrimSpace(d), "."))
+		if d != "" {
+			config.Syslog.domains = append(config.Syslog.domains, d)
+		}
+	}
+
+	config.Elasticsearch.interval = time.Duration(config.Elasticsearch.Interval) * time.Second
+	config.Elasticsearch.timeout = time.Duration(config.Elasticsearch.Timeout) * time.Second
+	config.Elasticsearch.maxBackoff = time.Duration(config.Elasticsearch.MaxBackoff) * time.Second
+	config.Elasticsearch.bulkURL = strings.TrimRight(config.Elasticsearch.URL, "/") + "/_bulk"
+	config.Elasticsearch.templateURL = strings.TrimRight(config.Elasticsearch.URL, "/") + "/_index_template/"
+
+	config.Events.interval = time.Duration(config.Events.Interval) * time.Second
+	config.Events.gravityMaxAge = time.Duration(config.Events.GravityMaxAge) * 24 * time.Hour
+	config.Events.renotifyInterval = time.Duration(config.Events.RenotifyInterval) * time.Second
+	for i := range config.Webhooks {
+		err = parseWebhookConfiguration(&config.Webhooks[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range config.Alerts {
+		err = parseAlertConfiguration(&config.Alerts[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config.Alertmanager.interval = time.Duration(config.Alertmanager.Interval) * time.Second
+	config.Alertmanager.timeout = time.Duration(config.Alertmanager.Timeout) * time.Second
+	config.Alertmanager.alertsURL = strings.TrimRight(config.Alertmanager.URL, "/") + "/api/v2/alerts"
+	config.Alertmanager.generatorURL = strings.TrimRight(config.Exporter.URL, "/") + config.Exporter.AlertsPath
+
+	if config.Auth.enabled {
+		err = parseAuthConfiguration(&config.Auth, config.AuthPaths)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config.History.resolution = int64(config.History.Resolution)
+	if config.History.Resolution > 0 {
+		config.History.slots = int64(config.History.Retention) * 86400 / config.History.resolution
+	}
+	config.History.saveInterval = time.Duration(config.History.SaveInterval) * time.Second
+
+	// the default schedule depends on the period of the report
+	if config.Report.Schedule == "" {
+		config.Report.Schedule = defaultReportDailySchedule
+		if config.Report.Period == "weekly" {
+			config.Report.Schedule = defaultReportWeeklySchedule
+		}
+	}
+	config.Report.schedule, err = parseCronSchedule(config.Report.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	config.Report.timeout = time.Duration(config.Report.Timeout) * time.Second
+	for _, rcpt := range strings.Split(config.Report.To, ",") {
+		rcpt = strings.TrimSpace(rcpt)
+		if rcpt != "" {
+			config.Report.to = append(config.Report.to, rcpt)
+		}
+	}
+	if config.Report.enabled {
+		config.Report.address, config.Report.host = parseSMTPServer(config.Report.SMTPServer, config.Report.SMTPTLS)
+	}
+
+	config.Pushgateway.interval = time.Duration(config.Pushgateway.Interval) * time.Second
+	config.Pushgateway.timeout = time.Duration(config.Pushgateway.Timeout) * time.Second
+	if config.Pushgateway.enabled {
+		config.Pushgateway.groupURL, err = buildPushgatewayGroupURL(config.Pushgateway, config.PiHole.Instance)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &config, nil
 }
 
@@ -61,6 +694,13 @@ func validateConfiguration(cfg Configuration) error {
 	if cfg.PiHole.Timeout == 0 {
 		return fmt.Errorf("Invalid timeout")
 	}
+	_url, err := url.Parse(cfg.PiHole.URL)
+	if err != nil {
+		return err
+	}
+	if _url.Scheme != "http" && _url.Scheme != "https" {
+		return fmt.Errorf("Invalid or unsupported URL scheme for PiHole")
+	}
 
 	if cfg.Exporter.PrometheusPath != "" && cfg.Exporter.PrometheusPath[0] != '/' {
 		return fmt.Errorf("Prometheus path must be an absolute path")
@@ -69,5 +709,1038 @@ func validateConfiguration(cfg Configuration) error {
 	if cfg.Exporter.InfluxDataPath != "" && cfg.Exporter.InfluxDataPath[0] != '/' {
 		return fmt.Errorf("InfluxDB path must be an absolute path")
 	}
+
+	if cfg.Exporter.JSONPath != "" && cfg.Exporter.JSONPath[0] != '/' {
+		return fmt.Errorf("JSON path must be an absolute path")
+	}
+
+	if cfg.Exporter.DashboardPath != "" {
+		if cfg.Exporter.DashboardPath[0] != '/' {
+			return fmt.Errorf("Dashboard path must be an absolute path")
+		}
+		if cfg.Exporter.JSONPath == "" {
+			return fmt.Errorf("Dashboard requires the JSON document, json_path is not set")
+		}
+	}
+
+	err = validateClientAuthConfiguration(cfg.Exporter)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Exporter.SSEPath != "" && cfg.Exporter.SSEPath[0] != '/' {
+		return fmt.Errorf("SSE path must be an absolute path")
+	}
+	if cfg.Exporter.SSEHeartbeat == 0 {
+		return fmt.Errorf("Invalid SSE heartbeat interval")
+	}
+	if cfg.Exporter.SSEMaxSubscribers == 0 {
+		return fmt.Errorf("Invalid maximal number of SSE subscribers")
+	}
+	if cfg.Exporter.PollInterval == 0 {
+		return fmt.Errorf("Invalid poll interval")
+	}
+
+	if cfg.History.enabled {
+		err := validateHistoryConfiguration(cfg.History, cfg.Exporter)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.InfluxDB.enabled {
+		err := validateInfluxDBConfiguration(cfg.InfluxDB)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.RemoteWrite.enabled {
+		err := validateRemoteWriteConfiguration(cfg.RemoteWrite)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Pushgateway.enabled {
+		err := validatePushgatewayConfiguration(cfg.Pushgateway)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Graphite.enabled {
+		err := validateGraphiteConfiguration(cfg.Graphite)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.StatsD.enabled {
+		err := validateStatsDConfiguration(cfg.StatsD)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.OTLP.enabled {
+		err := validateOTLPConfiguration(cfg.OTLP)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.MQTT.enabled {
+		err := validateMQTTConfiguration(cfg.MQTT)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Zabbix.enabled {
+		err := validateZabbixConfiguration(cfg.Zabbix)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Textfile.enabled {
+		err := validateTextfileConfiguration(cfg.Textfile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.AgentX.enabled {
+		err := validateAgentXConfiguration(cfg.AgentX)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.QueryLog.enabled && cfg.QueryLog.BatchSize == 0 {
+		return fmt.Errorf("Invalid query log batch size")
+	}
+
+	if cfg.Syslog.enabled {
+		if !cfg.QueryLog.enabled {
+			return fmt.Errorf("Syslog output requires the query log, database in section querylog is not set")
+		}
+
+		err := validateSyslogConfiguration(cfg.Syslog)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Elasticsearch.enabled {
+		if cfg.Elasticsearch.Queries && !cfg.QueryLog.enabled {
+			return fmt.Errorf("Indexing of queries requires the query log, database in section querylog is not set")
+		}
+
+		err := validateElasticsearchConfiguration(cfg.Elasticsearch)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(cfg.Webhooks) > 0 && cfg.Events.Interval == 0 {
+		return fmt.Errorf("Invalid events interval")
+	}
+
+	for _, hook := range cfg.Webhooks {
+		err := validateWebhookConfiguration(hook)
+		if err != nil {
+			return err
+		}
+	}
+
+	var alertNames = make(map[string]bool)
+	for _, rule := range cfg.Alerts {
+		if rule.name == "" || !labelNameRegexp.MatchString(rule.name) {
+			return fmt.Errorf("Invalid name %s of alert, only letters, digits and _ are allowed", rule.name)
+		}
+		if alertNames[rule.name] {
+			return fmt.Errorf("Duplicate alert %s", rule.name)
+		}
+		alertNames[rule.name] = true
+
+		if rule.Expr == "" {
+			return fmt.Errorf("Expression of alert %s is missing", rule.name)
+		}
+	}
+
+	if cfg.Alertmanager.enabled {
+		if len(cfg.Alerts) == 0 {
+			return fmt.Errorf("Alertmanager is configured but no alert rules are defined")
+		}
+
+		err := validateAlertmanagerConfiguration(cfg.Alertmanager)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateReportConfiguration(cfg.Report, cfg.QueryLog.enabled)
+	if err != nil {
+		return err
+	}
+
+	err = validateAuthConfiguration(cfg.Auth, cfg.AuthPaths)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Loki.enabled {
+		if !cfg.QueryLog.enabled {
+			return fmt.Errorf("Loki output requires the query log, database in section querylog is not set")
+		}
+
+		err := validateLokiConfiguration(cfg.Loki)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateClientAuthConfiguration(cfg ExporterConfiguration) error {
+	if cfg.ClientAuth != "none" && cfg.ClientAuth != "request" && cfg.ClientAuth != "require-and-verify" {
+		return fmt.Errorf("Invalid client_auth mode, only none, request or require-and-verify are supported")
+	}
+
+	// with request, clients without a certificate would bypass the allowed names
+	if cfg.ClientAuth != "require-and-verify" && strings.TrimSpace(cfg.ClientAllowedNames) != "" {
+		return fmt.Errorf("Allowed names of client certificates require client_auth require-and-verify")
+	}
+
+	if cfg.ClientAuth == "none" {
+		return nil
+	}
+
+	_url, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if _url.Scheme != "https" {
+		return fmt.Errorf("Client certificates require a HTTPS URL for the exporter")
+	}
+	if cfg.ClientCAFile == "" {
+		return fmt.Errorf("Verification of client certificates requires client_ca_file")
+	}
+
+	return nil
+}
+
+func validateInfluxDBConfiguration(cfg InfluxDBConfiguration) error {
+	_url, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if _url.Scheme != "http" && _url.Scheme != "https" {
+		return fmt.Errorf("Invalid or unsupported URL scheme for InfluxDB")
+	}
+
+	switch cfg.Version {
+	case 1:
+		if cfg.Database == "" {
+			return fmt.Errorf("InfluxDB database is missing")
+		}
+	case 2:
+		if cfg.Org == "" || cfg.Bucket == "" {
+			return fmt.Errorf("InfluxDB v2 requires org and bucket")
+		}
+		if cfg.Token == "" {
+			return fmt.Errorf("InfluxDB v2 requires an authentication token")
+		}
+	default:
+		return fmt.Errorf("Unsupported InfluxDB version, must be 1 or 2")
+	}
+
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid InfluxDB push interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid InfluxDB timeout")
+	}
+	if cfg.BatchSize == 0 {
+		return fmt.Errorf("Invalid InfluxDB batch size")
+	}
+	return nil
+}
+
+func buildInfluxDBWriteURL(cfg InfluxDBConfiguration) string {
+	params := url.Values{}
+	params.Set("precision", "ns")
+
+	if cfg.Version == 2 {
+		params.Set("org", cfg.Org)
+		params.Set("bucket", cfg.Bucket)
+		return strings.TrimRight(cfg.URL, "/") + "/api/v2/write?" + params.Encode()
+	}
+
+	params.Set("db", cfg.Database)
+	if cfg.RetentionPolicy != "" {
+		params.Set("rp", cfg.RetentionPolicy)
+	}
+	return strings.TrimRight(cfg.URL, "/") + "/write?" + params.Encode()
+}
+
+func validateRemoteWriteConfiguration(cfg RemoteWriteConfiguration) error {
+	_url, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if _url.Scheme != "http" && _url.Scheme != "https" {
+		return fmt.Errorf("Invalid or unsupported URL scheme for remote_write")
+	}
+
+	if cfg.Username != "" && cfg.BearerToken != "" {
+		return fmt.Errorf("Basic authentication and bearer token are mutually exclusive for remote_write")
+	}
+
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid remote_write interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid remote_write timeout")
+	}
+	if cfg.QueueSize == 0 {
+		return fmt.Errorf("Invalid remote_write queue size")
+	}
+	if cfg.MinBackoff == 0 || cfg.MaxBackoff < cfg.MinBackoff {
+		return fmt.Errorf("Invalid remote_write backoff")
+	}
+	return nil
+}
+
+func validatePushgatewayConfiguration(cfg PushgatewayConfiguration) error {
+	_url, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if _url.Scheme != "http" && _url.Scheme != "https" {
+		return fmt.Errorf("Invalid or unsupported URL scheme for Pushgateway")
+	}
+
+	if cfg.Job == "" {
+		return fmt.Errorf("Pushgateway job name is missing")
+	}
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid Pushgateway interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid Pushgateway timeout")
+	}
+	return nil
+}
+
+func validateGraphiteConfiguration(cfg GraphiteConfiguration) error {
+	if cfg.Protocol != "tcp" && cfg.Protocol != "udp" {
+		return fmt.Errorf("Invalid Graphite protocol, must be tcp or udp")
+	}
+	if !strings.Contains(cfg.PathTemplate, "{metric}") {
+		return fmt.Errorf("Graphite path template must contain {metric}")
+	}
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid Graphite interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid Graphite timeout")
+	}
+	return nil
+}
+
+func validateStatsDConfiguration(cfg StatsDConfiguration) error {
+	if cfg.Protocol != "udp" && cfg.Protocol != "unixgram" {
+		return fmt.Errorf("Invalid StatsD protocol, must be udp or unixgram")
+	}
+	if cfg.MTU < 512 || cfg.MTU > 65507 {
+		return fmt.Errorf("Invalid StatsD MTU, must be between 512 and 65507")
+	}
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid StatsD interval")
+	}
+	return nil
+}
+
+func validateOTLPConfiguration(cfg OTLPConfiguration) error {
+	_url, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if _url.Scheme != "http" && _url.Scheme != "https" {
+		return fmt.Errorf("Invalid or unsupported URL scheme for OTLP")
+	}
+
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid OTLP interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid OTLP timeout")
+	}
+	return nil
+}
+
+func validateMQTTConfiguration(cfg MQTTConfiguration) error {
+	_url, err := url.Parse(cfg.Broker)
+	if err != nil {
+		return err
+	}
+	switch _url.Scheme {
+	case "tcp", "ssl", "tls", "ws", "wss":
+	default:
+		return fmt.Errorf("Invalid or unsupported URL scheme for MQTT broker")
+	}
+
+	if cfg.QoS > 2 {
+		return fmt.Errorf("Invalid MQTT QoS, must be 0, 1 or 2")
+	}
+	if strings.ContainsAny(cfg.TopicPrefix, "+#") {
+		return fmt.Errorf("MQTT topic prefix must not contain wildcards")
+	}
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid MQTT interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid MQTT timeout")
+	}
+	return nil
+}
+
+func validateZabbixConfiguration(cfg ZabbixConfiguration) error {
+	if !zabbixKeyPrefixRegexp.MatchString(cfg.KeyPrefix) {
+		return fmt.Errorf("Invalid Zabbix item key prefix, only alphanumeric characters, _, - and . are allowed")
+	}
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid Zabbix interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid Zabbix timeout")
+	}
+	return nil
+}
+
+func validateTextfileConfiguration(cfg TextfileConfiguration) error {
+	if !filepath.IsAbs(cfg.Path) {
+		return fmt.Errorf("Path of the textfile must be an absolute path")
+	}
+	// the textfile collector only reads files with the extension .prom
+	if filepath.Ext(cfg.Path) != ".prom" {
+		return fmt.Errorf("Name of the textfile must end with .prom")
+	}
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid textfile interval")
+	}
+	return nil
+}
+
+func validateAgentXConfiguration(cfg AgentXConfiguration) error {
+	network, address := parseAgentXMaster(cfg.Master)
+	if network == "unix" && !filepath.IsAbs(address) {
+		return fmt.Errorf("Path of the AgentX socket must be an absolute path")
+	}
+	if network == "tcp" {
+		_, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return fmt.Errorf("Invalid address of the AgentX master: %s", err.Error())
+		}
+	}
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid AgentX interval")
+	}
+	// the timeout is sent as a single byte to the master agent
+	if cfg.Timeout == 0 || cfg.Timeout > 255 {
+		return fmt.Errorf("Invalid AgentX timeout, must be between 1 and 255")
+	}
+	_, err := parseAgentXOID(cfg.BaseOID)
+	if err != nil {
+		return fmt.Errorf("Invalid AgentX base OID: %s", err.Error())
+	}
+	return nil
+}
+
+func validateLokiConfiguration(cfg LokiConfiguration) error {
+	_url, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if _url.Scheme != "http" && _url.Scheme != "https" {
+		return fmt.Errorf("Invalid or unsupported URL scheme for Loki")
+	}
+
+	if cfg.CursorFile == "" {
+		return fmt.Errorf("Cursor file for Loki is missing")
+	}
+	if cfg.Events != "blocked" && cfg.Events != "all" {
+		return fmt.Errorf("Invalid events for Loki, must be blocked or all")
+	}
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid Loki interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid Loki timeout")
+	}
+	return nil
+}
+
+func validateSyslogConfiguration(cfg SyslogConfiguration) error {
+	if cfg.Protocol != "udp" && cfg.Protocol != "tcp" && cfg.Protocol != "tls" {
+		return fmt.Errorf("Invalid syslog protocol, must be udp, tcp or tls")
+	}
+	if cfg.Framing != "octet_counting" && cfg.Framing != "newline" {
+		return fmt.Errorf("Invalid syslog framing, must be octet_counting or newline")
+	}
+	if cfg.Format != "rfc5424" && cfg.Format != "cef" && cfg.Format != "leef" {
+		return fmt.Errorf("Invalid syslog format, must be rfc5424, cef or leef")
+	}
+	if _, found := syslogFacilities[cfg.Facility]; !found {
+		return fmt.Errorf("Invalid syslog facility %s", cfg.Facility)
+	}
+	if cfg.CursorFile == "" {
+		return fmt.Errorf("Cursor file for syslog is missing")
+	}
+	if cfg.Events != "blocked" && cfg.Events != "all" {
+		return fmt.Errorf("Invalid events for syslog, must be blocked or all")
+	}
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid syslog interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid syslog timeout")
+	}
+
+	for _, client := range strings.Split(cfg.Clients, ",") {
+		client = strings.TrimSpace(client)
+		if strings.Contains(client, "/") {
+			_, _, err := net.ParseCIDR(client)
+			if err != nil {
+				return fmt.Errorf("Invalid network %s in syslog clients", client)
+			}
+		}
+	}
+	return nil
+}
+
+func validateElasticsearchConfiguration(cfg ElasticsearchConfiguration) error {
+	_url, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if _url.Scheme != "http" && _url.Scheme != "https" {
+		return fmt.Errorf("Invalid or unsupported URL scheme for Elasticsearch")
+	}
+
+	if cfg.APIKey != "" && cfg.Username != "" {
+		return fmt.Errorf("Elasticsearch authentication by API key and by user name are mutually exclusive")
+	}
+	if !cfg.Snapshots && !cfg.Queries {
+		return fmt.Errorf("Neither snapshots nor queries are enabled for Elasticsearch")
+	}
+	if !elasticsearchIndexRegexp.MatchString(cfg.StatsIndex) || !elasticsearchIndexRegexp.MatchString(cfg.QueriesIndex) {
+		return fmt.Errorf("Invalid index name for Elasticsearch, only lower case letters, digits, - and _ are allowed")
+	}
+	if cfg.StatsIndex == cfg.QueriesIndex {
+		return fmt.Errorf("Index names for statistics and queries must be different")
+	}
+	if cfg.IndexRotation != "daily" && cfg.IndexRotation != "monthly" && cfg.IndexRotation != "none" {
+		return fmt.Errorf("Invalid index rotation for Elasticsearch, must be daily, monthly or none")
+	}
+	if cfg.Queries {
+		if cfg.CursorFile == "" {
+			return fmt.Errorf("Cursor file for Elasticsearch is missing")
+		}
+		if cfg.Events != "blocked" && cfg.Events != "all" {
+			return fmt.Errorf("Invalid events for Elasticsearch, must be blocked or all")
+		}
+	}
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid Elasticsearch interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid Elasticsearch timeout")
+	}
+	if cfg.MaxBackoff == 0 {
+		return fmt.Errorf("Invalid Elasticsearch maximal backoff")
+	}
+	return nil
+}
+
+func validateWebhookConfiguration(cfg WebhookConfiguration) error {
+	if cfg.name == "" {
+		return fmt.Errorf("Name of webhook is missing")
+	}
+
+	_url, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if _url.Scheme != "http" && _url.Scheme != "https" {
+		return fmt.Errorf("Invalid or unsupported URL scheme for webhook %s", cfg.name)
+	}
+
+	if cfg.Template != "" && cfg.TemplateFile != "" {
+		return fmt.Errorf("Options template and template_file of webhook %s are mutually exclusive", cfg.name)
+	}
+
+	for _, event := range strings.Split(cfg.Events, ",") {
+		event = strings.TrimSpace(event)
+		if event != "" && eventTitles[event] == "" {
+			return fmt.Errorf("Invalid event %s for webhook %s", event, cfg.name)
+		}
+	}
+
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid timeout for webhook %s", cfg.name)
+	}
+	return nil
+}
+
+// parseWebhookConfiguration - parse event list, headers and the template of a webhook
+func parseWebhookConfiguration(cfg *WebhookConfiguration) error {
+	var err error
+
+	cfg.timeout = time.Duration(cfg.Timeout) * time.Second
+
+	cfg.events = make(map[string]bool)
+	for _, event := range strings.Split(cfg.Events, ",") {
+		event = strings.TrimSpace(event)
+		if event != "" {
+			cfg.events[event] = true
+		}
+	}
+
+	cfg.headers, err = parseHeaderList(cfg.Headers)
+	if err != nil {
+		return err
+	}
+
+	text := cfg.Template
+	if cfg.TemplateFile != "" {
+		data, err := ioutil.ReadFile(cfg.TemplateFile)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	}
+	if text == "" {
+		text = defaultWebhookTemplate
+	}
+
+	cfg.template, err = template.New(cfg.name).Funcs(webhookTemplateFunctions).Parse(text)
+	if err != nil {
+		return fmt.Errorf("Can't parse template of webhook %s: %s", cfg.name, err.Error())
+	}
+
+	return nil
+}
+
+func validateAlertmanagerConfiguration(cfg AlertmanagerConfiguration) error {
+	_url, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if _url.Scheme != "http" && _url.Scheme != "https" {
+		return fmt.Errorf("Invalid or unsupported URL scheme for Alertmanager")
+	}
+
+	if cfg.Interval == 0 {
+		return fmt.Errorf("Invalid Alertmanager interval")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid Alertmanager timeout")
+	}
+	return nil
+}
+
+func validateHistoryConfiguration(cfg HistoryConfiguration, exporter ExporterConfiguration) error {
+	if exporter.HistoryPath != "" && exporter.HistoryPath[0] != '/' {
+		return fmt.Errorf("History path must be an absolute path")
+	}
+	if cfg.Retention == 0 {
+		return fmt.Errorf("Invalid retention of the history")
+	}
+	if cfg.Resolution == 0 {
+		return fmt.Errorf("Invalid resolution of the history")
+	}
+	// a slot without a poll would be a gap in the history
+	if cfg.Resolution < exporter.PollInterval {
+		return fmt.Errorf("Resolution of the history must not be less than poll_interval")
+	}
+	if uint64(cfg.Retention)*86400/uint64(cfg.Resolution) > maxHistorySlots {
+		return fmt.Errorf("Retention of the history is too long for the resolution, at most %d values per metric are supported", maxHistorySlots)
+	}
+	if cfg.File != "" && cfg.SaveInterval == 0 {
+		return fmt.Errorf("Invalid save interval of the history")
+	}
+
+	return nil
+}
+
+// validateReportConfiguration - the PiHole API only provides the values since midnight, complete days require the query log
+func validateReportConfiguration(cfg ReportConfiguration, queryLog bool) error {
+	if cfg.Period != "daily" && cfg.Period != "weekly" {
+		return fmt.Errorf("Invalid report period, only daily or weekly are supported")
+	}
+	if cfg.Period == "weekly" && !queryLog {
+		return fmt.Errorf("Weekly reports require the query log, database in section querylog is not set")
+	}
+	if cfg.Top == 0 {
+		return fmt.Errorf("Invalid number of top entries in the report")
+	}
+
+	if !cfg.enabled {
+		return nil
+	}
+
+	if !queryLog {
+		return fmt.Errorf("Scheduled reports require the query log, database in section querylog is not set")
+	}
+
+	if cfg.SMTPTLS != "starttls" && cfg.SMTPTLS != "tls" && cfg.SMTPTLS != "none" {
+		return fmt.Errorf("Invalid smtp_tls mode for the report, only starttls, tls or none are supported")
+	}
+	if cfg.From == "" {
+		return fmt.Errorf("Sender of the report is missing")
+	}
+	_, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return fmt.Errorf("Invalid sender %s of the report", cfg.From)
+	}
+	if cfg.To == "" {
+		return fmt.Errorf("Recipients of the report are missing")
+	}
+	_, err = mail.ParseAddressList(cfg.To)
+	if err != nil {
+		return fmt.Errorf("Invalid recipients %s of the report", cfg.To)
+	}
+	if cfg.Username != "" && cfg.SMTPTLS == "none" {
+		return fmt.Errorf("SMTP authentication for the report requires smtp_tls starttls or tls")
+	}
+	if cfg.Timeout == 0 {
+		return fmt.Errorf("Invalid report timeout")
+	}
+
+	return nil
+}
+
+func validateAuthConfiguration(cfg AuthConfiguration, paths []AuthPathConfiguration) error {
+	if !cfg.enabled {
+		if len(paths) > 0 {
+			return fmt.Errorf("Authentication policies of paths require users_file or tokens_file in section auth")
+		}
+		return nil
+	}
+
+	if strings.ContainsAny(cfg.Realm, `"\`) {
+		return fmt.Errorf("Invalid authentication realm, quotes and backslashes are not allowed")
+	}
+
+	err := validateAuthMethods(cfg.Methods, cfg)
+	if err != nil {
+		return fmt.Errorf("%s in section auth", err.Error())
+	}
+
+	var seen = make(map[string]bool)
+	for _, policy := range paths {
+		if policy.path == "" || policy.path[0] != '/' {
+			return fmt.Errorf("Path %s of authentication policy must be an absolute path", policy.path)
+		}
+		if seen[policy.path] {
+			return fmt.Errorf("Duplicate authentication policy for path %s", policy.path)
+		}
+		seen[policy.path] = true
+
+		methods := policy.Methods
+		if methods == "" {
+			methods = cfg.Methods
+		}
+		err = validateAuthMethods(methods, cfg)
+		if err != nil {
+			return fmt.Errorf("%s for path %s", err.Error(), policy.path)
+		}
+		// a bearer token isn't bound to a user, so it would bypass the restriction
+		parsed := parseAuthMethods(methods)
+		if policy.Users != "" && (len(parsed) != 1 || !parsed["basic"]) {
+			return fmt.Errorf("Users for path %s require basic as the only authentication method", policy.path)
+		}
+	}
+
+	return nil
+}
+
+// validateAuthMethods - methods are basic and bearer, none makes a path public
+func validateAuthMethods(methods string, cfg AuthConfiguration) error {
+	parsed := parseAuthMethods(methods)
+
+	if len(parsed) == 0 {
+		return fmt.Errorf("No authentication method")
+	}
+	if parsed["none"] && len(parsed) > 1 {
+		return fmt.Errorf("Authentication method none can't be combined with other methods")
+	}
+
+	for method := range parsed {
+		switch method {
+		case "basic":
+			if cfg.UsersFile == "" {
+				return fmt.Errorf("Authentication method basic requires users_file")
+			}
+		case "bearer":
+			if cfg.TokensFile == "" {
+				return fmt.Errorf("Authentication method bearer requires tokens_file")
+			}
+		case "none":
+		default:
+			return fmt.Errorf("Invalid authentication method %s", method)
+		}
+	}
+
+	return nil
+}
+
+// parseAuthConfiguration - load users and tokens and parse the policies of the paths
+func parseAuthConfiguration(cfg *AuthConfiguration, paths []AuthPathConfiguration) error {
+	var err error
+
+	cfg.methods = parseAuthMethods(cfg.Methods)
+
+	if cfg.UsersFile != "" {
+		cfg.users, err = loadAuthUsers(cfg.UsersFile)
+		if err != nil {
+			return err
+		}
+
+		// unknown users are compared against a hash with the highest cost to hide which users exist
+		cost := bcrypt.DefaultCost
+		for _, hash := range cfg.users {
+			c, _ := bcrypt.Cost(hash)
+			if c > cost {
+				cost = c
+			}
+		}
+		cfg.dummyHash, err = bcrypt.GenerateFromPassword([]byte(name), cost)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.TokensFile != "" {
+		cfg.tokens, err = loadAuthTokens(cfg.TokensFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range paths {
+		paths[i].methods = cfg.methods
+		if paths[i].Methods != "" {
+			paths[i].methods = parseAuthMethods(paths[i].Methods)
+		}
+
+		paths[i].users = make(map[string]bool)
+		for _, user := range strings.Split(paths[i].Users, ",") {
+			user = strings.TrimSpace(user)
+			if user == "" {
+				continue
+			}
+			if cfg.users[user] == nil {
+				return fmt.Errorf("User %s for path %s is not defined in %s", user, paths[i].path, cfg.UsersFile)
+			}
+			paths[i].users[user] = true
+		}
+	}
+
+	return nil
+}
+
+func parseAuthMethods(methods string) map[string]bool {
+	var result = make(map[string]bool)
+
+	for _, method := range strings.Split(methods, ",") {
+		method = strings.TrimSpace(method)
+		if method != "" {
+			result[method] = true
+		}
+	}
+	return result
+}
+
+// parseSMTPServer - returns the address to connect to and the host name to verify the certificate
+func parseSMTPServer(server string, mode string) (string, string) {
+	host, _, err := net.SplitHostPort(server)
+	if err == nil {
+		return server, host
+	}
+
+	port := defaultReportSMTPPort
+	if mode == "tls" {
+		port = defaultReportSMTPTLSPort
+	}
+
+	return net.JoinHostPort(server, port), server
+}
+
+// parseAlertConfiguration - parse expression, labels and annotations of an alert rule
+func parseAlertConfiguration(cfg *AlertConfiguration) error {
+	var err error
+
+	err = parseAlertExpression(cfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.labels, err = parseLabelList(cfg.Labels)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"alertname", "instance"} {
+		_, found := cfg.labels[name]
+		if found {
+			return fmt.Errorf("Label %s of alert %s is set by the exporter and can't be used in labels", name, cfg.name)
+		}
+	}
+
+	cfg.annotations = make(map[string]*template.Template)
+	for name, text := range map[string]string{"summary": cfg.Summary, "description": cfg.Description} {
+		if text == "" {
+			continue
+		}
+
+		cfg.annotations[name], err = template.New(cfg.name + "_" + name).Parse(text)
+		if err != nil {
+			return fmt.Errorf("Can't parse %s of alert %s: %s", name, cfg.name, err.Error())
+		}
+	}
+
 	return nil
 }
+
+// parseSyslogClients - clients are IP addresses, networks in CIDR notation or host names
+func parseSyslogClients(s string) ([]*net.IPNet, map[string]bool) {
+	var nets []*net.IPNet
+	var names = make(map[string]bool)
+
+	for _, client := range strings.Split(s, ",") {
+		client = strings.TrimSpace(client)
+		if client == "" {
+			continue
+		}
+
+		if strings.Contains(client, "/") {
+			_, n, err := net.ParseCIDR(client)
+			if err == nil {
+				nets = append(nets, n)
+			}
+			continue
+		}
+
+		ip := net.ParseIP(client)
+		if ip != nil {
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+
+		names[strings.ToLower(client)] = true
+	}
+
+	return nets, names
+}
+
+// sanitizeSyslogHeaderField - header fields of RFC 5424 consist of printable US-ASCII characters without spaces
+func sanitizeSyslogHeaderField(s string, maxLen int) string {
+	var result []byte
+
+	for i := 0; i < len(s) && len(result) < maxLen; i++ {
+		if s[i] > 32 && s[i] < 127 {
+			result = append(result, s[i])
+		} else {
+			result = append(result, '_')
+		}
+	}
+
+	if len(result) == 0 {
+		return "-"
+	}
+	return string(result)
+}
+
+// parseAgentXMaster - address of the master agent in the format of the agentXSocket option of net-snmp, e.g. /var/agentx/master or tcp:localhost:705
+func parseAgentXMaster(s string) (string, string) {
+	if strings.HasPrefix(s, "tcp:") {
+		return "tcp", strings.TrimPrefix(s, "tcp:")
+	}
+	return "unix", strings.TrimPrefix(s, "unix:")
+}
+
+// parseAgentXOID - numeric OID with or without leading dot, e.g. .1.3.6.1.4.1.8072.9999.9999.4711
+func parseAgentXOID(s string) ([]uint32, error) {
+	var result []uint32
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), ".")
+	for _, subid := range strings.Split(s, ".") {
+		value, err := strconv.ParseUint(subid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a numeric sub identifier", subid)
+		}
+		result = append(result, uint32(value))
+	}
+
+	// the first sub identifier is 0 (ITU-T), 1 (ISO) or 2 (joint), objects are at least two levels deeper
+	if len(result) < 2 || result[0] > 2 {
+		return nil, fmt.Errorf("%s is not a valid OID", s)
+	}
+	// AgentX allows at most 128 sub identifiers, the objects of the MIB add up to four
+	if len(result) > 124 {
+		return nil, fmt.Errorf("OID is too long")
+	}
+
+	return result, nil
+}
+
+// parseHeaderList - parse a comma separated list of name=value HTTP headers
+func parseHeaderList(s string) (map[string]string, error) {
+	var result = make(map[string]string)
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("Invalid header definition %s", pair)
+		}
+
+		result[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return result, nil
+}
+
+// parseLabelList - parse a comma separated list of name=value pairs
+func parseLabelList(s string) (map[string]string, error) {
+	var result = make(map[string]string)
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid label definition %s", pair)
+		}
+
+		name := strings.TrimSpace(kv[0])
+		if !labelNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("Invalid label name %s", name)
+		}
+
+		result[name] = strings.TrimSpace(kv[1])
+	}
+
+	return result, nil
+}
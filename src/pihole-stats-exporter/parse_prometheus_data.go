@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parsePrometheusData - split the Prometheus text format generated by the exporter into single samples
+func parsePrometheusData(data []byte) ([]PrometheusSample, error) {
+	var result []PrometheusSample
+	var metricType = make(map[string]string)
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(strings.TrimLeft(line, "#"))
+			if len(fields) == 3 && fields[0] == "TYPE" {
+				metricType[fields[1]] = fields[2]
+			}
+			continue
+		}
+
+		sample, err := parsePrometheusLine(line)
+		if err != nil {
+			return nil, err
+		}
+		sample.Type = metricType[sample.Name]
+
+		result = append(result, sample)
+	}
+
+	return result, nil
+}
+
+func parsePrometheusLine(line string) (PrometheusSample, error) {
+	var result = PrometheusSample{
+		Labels: make(map[string]string),
+	}
+	var err error
+	var rest string
+
+	pos := strings.IndexAny(line, "{ ")
+	if pos <= 0 {
+		return result, fmt.Errorf("Invalid metric line: %s", line)
+	}
+
+	result.Name = line[:pos]
+	rest = line[pos:]
+
+	if rest[0] == '{' {
+		rest, err = parsePrometheusLabels(rest[1:], result.Labels)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return result, fmt.Errorf("Metric line contains no value: %s", line)
+	}
+
+	result.Value, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func parsePrometheusLabels(s string, labels map[string]string) (string, error) {
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if s == "" {
+			return s, fmt.Errorf("Unterminated label set")
+		}
+
+		if s[0] == '}' {
+			return s[1:], nil
+		}
+
+		eq := strings.Index(s, "=\"")
+		if eq <= 0 {
+			return s, fmt.Errorf("Invalid label definition")
+		}
+		name := s[:eq]
+		s = s[eq+2:]
+
+		var value strings.Builder
+		var i int
+		for i = 0; i < len(s); i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			if s[i] == '"' {
+				break
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return s, fmt.Errorf("Unterminated label value")
+		}
+
+		labels[name] = value.String()
+		s = s[i+1:]
+	}
+}
+
+// sortedLabelNames - label names of a sample in lexical order
+func sortedLabelNames(labels map[string]string) []string {
+	var result []string
+
+	for name := range labels {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,30 @@
+package main
+
+// getPiHoleValues - flat list of the values reported by the PiHole server, used by outputs without label support
+func getPiHoleValues(rawsum PiHoleRawSummary, qtypes PiHoleQueryTypes) []PiHoleValue {
+	return []PiHoleValue{
+		{Group: "summary", Name: "domains_being_blocked", Value: float64(rawsum.DomainsBeingBlocked)},
+		{Group: "summary", Name: "dns_queries_today", Value: float64(rawsum.DNSQueriesToday)},
+		{Group: "summary", Name: "ads_blocked_today", Value: float64(rawsum.AdsBlockedToday)},
+		{Group: "summary", Name: "ads_percentage_today", Value: rawsum.AdsPercentageToday},
+		{Group: "summary", Name: "unique_domains", Value: float64(rawsum.UniqueDomains)},
+		{Group: "summary", Name: "queries_forwarded", Value: float64(rawsum.QueriesForwarded)},
+		{Group: "summary", Name: "queries_cached", Value: float64(rawsum.QueriesCached)},
+		{Group: "summary", Name: "clients_ever_seen", Value: float64(rawsum.ClientsEverSeend)},
+		{Group: "summary", Name: "unique_clients", Value: float64(rawsum.UniqueClients)},
+		{Group: "summary", Name: "dns_queries_all_types", Value: float64(rawsum.DNSQueriesAllTypes)},
+		{Group: "summary", Name: "privacy_level", Value: float64(rawsum.PrivacyLevel)},
+		{Group: "reply", Name: "NODATA", Value: float64(rawsum.ReplyNODATA)},
+		{Group: "reply", Name: "NXDOMAIN", Value: float64(rawsum.ReplyNXDOMAIN)},
+		{Group: "reply", Name: "CNAME", Value: float64(rawsum.ReplyCNAME)},
+		{Group: "reply", Name: "IP", Value: float64(rawsum.ReplyIP)},
+		{Group: "querytypes", Name: "A", Value: qtypes.Querytypes.A},
+		{Group: "querytypes", Name: "AAAA", Value: qtypes.Querytypes.AAAA},
+		{Group: "querytypes", Name: "ANY", Value: qtypes.Querytypes.ANY},
+		{Group: "querytypes", Name: "SRV", Value: qtypes.Querytypes.SRV},
+		{Group: "querytypes", Name: "SOA", Value: qtypes.Querytypes.SOA},
+		{Group: "querytypes", Name: "PTR", Value: qtypes.Querytypes.PTR},
+		{Group: "querytypes", Name: "TXT", Value: qtypes.Querytypes.TXT},
+		{Group: "querytypes", Name: "NAPTR", Value: qtypes.Querytypes.NAPTR},
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// result of the last background poll and channels of the consumers waiting for the next one
+var pollerLock sync.Mutex
+var pollerLast *PollSnapshot
+var pollerSequence uint64
+var pollerSubscribers = make(map[chan PollSnapshot]bool)
+
+// backgroundPoller - fetch the data at a regular interval for consumers which are notified on every poll
+func backgroundPoller() {
+	log.WithFields(log.Fields{
+		"poll_interval": config.Exporter.pollInterval.String(),
+	}).Info(formatLogString("Starting background poll of the PiHole server"))
+
+	ticker := time.NewTicker(config.Exporter.pollInterval)
+	for {
+		snapshot := publishPollSnapshot(generateJSONDocument())
+
+		if len(config.Alerts) > 0 {
+			evaluateAlerts(snapshot.Document, time.Now())
+		}
+
+		if config.History.enabled {
+			addHistory(snapshot.Document, time.Now())
+		}
+
+		<-ticker.C
+	}
+}
+
+func publishPollSnapshot(doc JSONDocument) PollSnapshot {
+	pollerLock.Lock()
+	defer pollerLock.Unlock()
+
+	pollerSequence++
+	snapshot := PollSnapshot{
+		ID:       pollerSequence,
+		Document: doc,
+	}
+
+	pollerLast = &snapshot
+
+	// slow consumers miss the snapshot instead of blocking the poll
+	for ch := range pollerSubscribers {
+		select {
+		case ch <- snapshot:
+		default:
+		}
+	}
+
+	return snapshot
+}
+
+// nextPollSnapshotID - snapshots which are not created by the background poll use the same sequence
+func nextPollSnapshotID() uint64 {
+	pollerLock.Lock()
+	defer pollerLock.Unlock()
+
+	pollerSequence++
+	return pollerSequence
+}
+
+func subscribePoller() chan PollSnapshot {
+	ch := make(chan PollSnapshot, 1)
+
+	pollerLock.Lock()
+	pollerSubscribers[ch] = true
+	pollerLock.Unlock()
+
+	return ch
+}
+
+func unsubscribePoller(ch chan PollSnapshot) {
+	pollerLock.Lock()
+	delete(pollerSubscribers, ch)
+	pollerLock.Unlock()
+}
+
+// getLastPollSnapshot - last snapshot, nil if the PiHole server was never polled
+func getLastPollSnapshot() *PollSnapshot {
+	pollerLock.Lock()
+	defer pollerLock.Unlock()
+
+	return pollerLast
+}
+
+// getPollMetrics - values of a poll by name, used by alert rules and the history
+func getPollMetrics(doc JSONDocument) map[string]float64 {
+	var result = make(map[string]float64)
+
+	if doc.Summary == nil {
+		result["up"] = 0
+		return result
+	}
+	result["up"] = 1
+
+	if doc.Blocking.Enabled {
+		result["blocking"] = 1
+	} else {
+		result["blocking"] = 0
+	}
+
+	result["block_ratio"] = doc.Summary.AdsRatioToday
+
+	if doc.Gravity.LastUpdated > 0 {
+		result["gravity_age"] = float64(doc.Gravity.AgeSeconds)
+	}
+
+	// use the names of the JSON document for the summary
+	var summary map[string]float64
+	data, err := json.Marshal(doc.Summary)
+	if err == nil && json.Unmarshal(data, &summary) == nil {
+		for name, value := range summary {
+			result[name] = value
+		}
+	}
+
+	for name, value := range doc.Replies {
+		result["reply_"+name] = float64(value)
+	}
+
+	for name, value := range doc.QueryTypes {
+		result["querytype_"+name] = value
+	}
+
+	return result
+}
+
+// isPollMetric - true if getPollMetrics provides the metric for a successful poll
+func isPollMetric(name string) bool {
+	doc := JSONDocument{
+		Blocking:   &JSONBlockingStatus{},
+		Summary:    &JSONSummary{},
+		Replies:    getJSONReplies(PiHoleRawSummary{}),
+		QueryTypes: getJSONQueryTypes(PiHoleQueryTypes{}),
+		Gravity:    &JSONGravity{LastUpdated: 1},
+	}
+
+	_, found := getPollMetrics(doc)[name]
+	return found
+}
@@ -20,12 +20,13 @@ func prometheusExporter(response http.ResponseWriter, request *http.Request) {
 		"host":           request.Host,
 		"remote_address": request.RemoteAddr,
 		"headers":        fmt.Sprintf("%+v\n", request.Header),
+		"client_cert":    getClientIdentity(request),
 	}).Info(formatLogString("HTTP request from client received"))
 
 	response.Header().Add("X-Clacks-Overhead", "GNU Terry Pratchett")
 
 	// get raw summary
-	rawsum, err = getPiHoleRawSummary(request)
+	rawsum, err = getPiHoleRawSummary()
 	if err != nil {
 		log.WithFields(log.Fields{
 			"remote_address": request.RemoteAddr,
@@ -40,7 +41,7 @@ func prometheusExporter(response http.ResponseWriter, request *http.Request) {
 	}
 
 	// get DNS queriey by type
-	qtypes, err = getPiHoleQueryTypes(request)
+	qtypes, err = getPiHoleQueryTypes()
 	if err != nil {
 		log.WithFields(log.Fields{
 			"remote_address": request.RemoteAddr,
@@ -54,7 +55,25 @@ func prometheusExporter(response http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	payload = []byte(fmt.Sprintf(`#HELP pihole_domains_blocked_total Number of blocked domains
+	payload = generatePrometheusData(rawsum, qtypes)
+
+	// metrics of the exporter itself are only provided for scraping, not to the other outputs of generatePrometheusData
+	if config.InfluxDB.enabled {
+		payload = append(payload, generateInfluxDBPushMetrics()...)
+	}
+
+	if len(config.Alerts) > 0 {
+		payload = append(payload, generateAlertsMetrics()...)
+	}
+
+	response.Write(payload)
+
+	// discard slice and force gc to free the allocated memory
+	payload = nil
+}
+
+func generatePrometheusData(rawsum PiHoleRawSummary, qtypes PiHoleQueryTypes) []byte {
+	payload := []byte(fmt.Sprintf(`#HELP pihole_domains_blocked_total Number of blocked domains
 #TYPE pihole_domains_blocked_total counter
 pihole_domains_blocked_total{upstream="%s"} %d
 #HELP pihole_dns_queries_today_total Number of DNS queries received today
@@ -128,8 +147,6 @@ pihole_query_type_ratio{upstream="%s",type="NAPTR"} %f
 		config.PiHole.URL, float64(qtypes.Querytypes.TXT)/100.0,
 		config.PiHole.URL, float64(qtypes.Querytypes.NAPTR)/100.0,
 	))
-	response.Write(payload)
 
-	// discard slice and force gc to free the allocated memory
-	payload = nil
+	return payload
 }
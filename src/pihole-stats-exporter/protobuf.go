@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Minimal protocol buffer encoder, only the wire types required for the messages send by the exporter are supported
+
+const protobufWireVarint = 0
+const protobufWireFixed64 = 1
+const protobufWireBytes = 2
+
+func protobufAppendVarint(buffer []byte, value uint64) []byte {
+	for value >= 0x80 {
+		buffer = append(buffer, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(buffer, byte(value))
+}
+
+func protobufAppendTag(buffer []byte, field uint64, wireType uint64) []byte {
+	return protobufAppendVarint(buffer, field<<3|wireType)
+}
+
+func protobufAppendUint64(buffer []byte, field uint64, value uint64) []byte {
+	buffer = protobufAppendTag(buffer, field, protobufWireVarint)
+	return protobufAppendVarint(buffer, value)
+}
+
+func protobufAppendInt64(buffer []byte, field uint64, value int64) []byte {
+	return protobufAppendUint64(buffer, field, uint64(value))
+}
+
+func protobufAppendBool(buffer []byte, field uint64, value bool) []byte {
+	if value {
+		return protobufAppendUint64(buffer, field, 1)
+	}
+	return protobufAppendUint64(buffer, field, 0)
+}
+
+func protobufAppendFixed64(buffer []byte, field uint64, value uint64) []byte {
+	buffer = protobufAppendTag(buffer, field, protobufWireFixed64)
+	return binary.LittleEndian.AppendUint64(buffer, value)
+}
+
+func protobufAppendDouble(buffer []byte, field uint64, value float64) []byte {
+	return protobufAppendFixed64(buffer, field, math.Float64bits(value))
+}
+
+func protobufAppendBytes(buffer []byte, field uint64, value []byte) []byte {
+	buffer = protobufAppendTag(buffer, field, protobufWireBytes)
+	buffer = protobufAppendVarint(buffer, uint64(len(value)))
+	return append(buffer, value...)
+}
+
+func protobufAppendString(buffer []byte, field uint64, value string) []byte {
+	return protobufAppendBytes(buffer, field, []byte(value))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// serialise push and delete, no metrics must be pushed after the group has been deleted on shutdown
+var pushgatewayLock sync.Mutex
+var pushgatewayDeleted bool
+
+func pushgatewayPusher() {
+	log.WithFields(log.Fields{
+		"pushgateway_url": config.Pushgateway.URL,
+		"group_url":       config.Pushgateway.groupURL,
+		"interval":        config.Pushgateway.interval.String(),
+	}).Info(formatLogString("Starting push of metrics to Pushgateway"))
+
+	ticker := time.NewTicker(config.Pushgateway.interval)
+	for {
+		pushPushgateway()
+		<-ticker.C
+	}
+}
+
+func pushPushgateway() {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	payload := generatePrometheusData(rawsum, qtypes)
+
+	pushgatewayLock.Lock()
+	defer pushgatewayLock.Unlock()
+
+	if pushgatewayDeleted {
+		return
+	}
+
+	// PUT replaces all metrics of the group
+	err = sendPushgatewayRequest("PUT", payload)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"pushgateway_url": config.Pushgateway.URL,
+			"group_url":       config.Pushgateway.groupURL,
+			"error":           err.Error(),
+		}).Error(formatLogString("Can't push metrics to Pushgateway"))
+	}
+}
+
+func deletePushgatewayGroup() {
+	pushgatewayLock.Lock()
+	defer pushgatewayLock.Unlock()
+
+	pushgatewayDeleted = true
+
+	err := sendPushgatewayRequest("DELETE", nil)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"pushgateway_url": config.Pushgateway.URL,
+			"group_url":       config.Pushgateway.groupURL,
+			"error":           err.Error(),
+		}).Error(formatLogString("Can't delete metrics group from Pushgateway"))
+
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"pushgateway_url": config.Pushgateway.URL,
+		"group_url":       config.Pushgateway.groupURL,
+	}).Info(formatLogString("Metrics group deleted from Pushgateway"))
+}
+
+func sendPushgatewayRequest(method string, payload []byte) error {
+	header := map[string]string{
+		"Content-Type": "text/plain; version=0.0.4; charset=utf-8",
+	}
+
+	if config.Pushgateway.Username != "" {
+		header["Authorization"] = basicAuthHeader(config.Pushgateway.Username, config.Pushgateway.Password)
+	}
+
+	result, err := httpRequest(config.Pushgateway.groupURL, method, header, payload, config.Pushgateway.InsecureSSL, config.Pushgateway.CAFile, config.Pushgateway.timeout)
+	if err != nil {
+		return err
+	}
+
+	if result.StatusCode < 200 || result.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected HTTP status from Pushgateway: %s (%s)", result.Status, strings.TrimSpace(string(result.Content)))
+	}
+
+	return nil
+}
+
+// buildPushgatewayGroupURL - URL of the job and grouping key, the instance name is used as grouping key if none is configured
+func buildPushgatewayGroupURL(cfg PushgatewayConfiguration, instance string) (string, error) {
+	var result = strings.TrimRight(cfg.URL, "/") + "/metrics" + pushgatewayPathElement("job", cfg.Job)
+
+	grouping, err := parseLabelList(cfg.GroupingKey)
+	if err != nil {
+		return "", err
+	}
+
+	if len(grouping) == 0 {
+		grouping["instance"] = instance
+	}
+
+	for _, name := range sortedLabelNames(grouping) {
+		if name == "job" {
+			return "", fmt.Errorf("Label job can't be used in the grouping key of the Pushgateway")
+		}
+
+		result += pushgatewayPathElement(name, grouping[name])
+	}
+
+	return result, nil
+}
+
+// pushgatewayPathElement - values containing a slash or empty values must be base64 encoded
+func pushgatewayPathElement(name string, value string) string {
+	if value == "" {
+		return "/" + name + "@base64/="
+	}
+
+	if strings.Contains(value, "/") {
+		return "/" + name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+
+	return "/" + name + "/" + url.PathEscape(value)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+)
+
+var queryLogDB *sql.DB
+var queryLogLock sync.Mutex
+
+// status codes of the queries table of the FTL database
+var queryLogStatus = map[int]string{
+	0:  "unknown",
+	1:  "gravity",
+	2:  "forwarded",
+	3:  "cache",
+	4:  "regex",
+	5:  "denylist",
+	6:  "external_blocked_ip",
+	7:  "external_blocked_null",
+	8:  "external_blocked_nxra",
+	9:  "gravity_cname",
+	10: "regex_cname",
+	11: "denylist_cname",
+	12: "retried",
+	13: "retried_dnssec",
+	14: "in_progress",
+	15: "dbbusy",
+	16: "special_domain",
+	17: "cache_stale",
+	18: "external_blocked_ede15",
+}
+
+var queryLogBlockedStatus = map[int]bool{
+	1:  true,
+	4:  true,
+	5:  true,
+	6:  true,
+	7:  true,
+	8:  true,
+	9:  true,
+	10: true,
+	11: true,
+	15: true,
+	16: true,
+	18: true,
+}
+
+// query types of the queries table of the FTL database
+var queryLogType = map[int]string{
+	1:  "A",
+	2:  "AAAA",
+	3:  "ANY",
+	4:  "SRV",
+	5:  "SOA",
+	6:  "PTR",
+	7:  "TXT",
+	8:  "NAPTR",
+	9:  "MX",
+	10: "DS",
+	11: "RRSIG",
+	12: "DNSKEY",
+	13: "NS",
+	14: "OTHER",
+	15: "SVCB",
+	16: "HTTPS",
+}
+
+// openQueryLog - the database is opened read-only, FTL is the only writer
+func openQueryLog() (*sql.DB, error) {
+	queryLogLock.Lock()
+	defer queryLogLock.Unlock()
+
+	if queryLogDB != nil {
+		return queryLogDB, nil
+	}
+
+	// fail early instead of creating an empty database if the path is wrong
+	_, err := os.Stat(config.QueryLog.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+config.QueryLog.Database+"?mode=ro&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	queryLogDB = db
+	return queryLogDB, nil
+}
+
+// readQueryLog - fetch up to limit queries with an ID greater than after, ordered by ID
+func readQueryLog(after int64, limit uint) ([]QueryLogEntry, error) {
+	var result []QueryLogEntry
+
+	db, err := openQueryLog()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT id, timestamp, type, status, domain, client FROM queries WHERE id > ? ORDER BY id LIMIT ?", after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry QueryLogEntry
+		var timestamp float64
+		var qtype int
+		var status int
+
+		err = rows.Scan(&entry.ID, &timestamp, &qtype, &status, &entry.Domain, &entry.Client)
+		if err != nil {
+			return nil, err
+		}
+
+		// FTL v5 stores seconds as integer, newer versions as float
+		sec, frac := math.Modf(timestamp)
+		entry.Timestamp = time.Unix(int64(sec), int64(frac*1e+09))
+
+		entry.Type = queryLogType[qtype]
+		if entry.Type == "" {
+			entry.Type = fmt.Sprintf("TYPE%d", qtype)
+		}
+
+		entry.Status = queryLogStatus[status]
+		if entry.Status == "" {
+			entry.Status = "unknown"
+		}
+		entry.Blocked = queryLogBlockedStatus[status]
+
+		result = append(result, entry)
+	}
+
+	return result, rows.Err()
+}
+
+func getQueryLogMaxID() (int64, error) {
+	var maxID sql.NullInt64
+
+	db, err := openQueryLog()
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.QueryRow("SELECT MAX(id) FROM queries").Scan(&maxID)
+	if err != nil {
+		return 0, err
+	}
+
+	return maxID.Int64, nil
+}
+
+// readQueryLogCursor - read the ID of the last processed query, start with the newest query if no cursor was stored yet
+func readQueryLogCursor(cursorFile string) (int64, error) {
+	data, err := ioutil.ReadFile(cursorFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return 0, err
+		}
+
+		maxID, err := getQueryLogMaxID()
+		if err != nil {
+			return 0, err
+		}
+
+		log.WithFields(log.Fields{
+			"cursor_file": cursorFile,
+			"id":          maxID,
+		}).Info(formatLogString("No cursor for the query log found, starting with the newest query"))
+
+		return maxID, writeQueryLogCursor(cursorFile, maxID)
+	}
+
+	cursor, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid content of cursor file %s", cursorFile)
+	}
+
+	maxID, err := getQueryLogMaxID()
+	if err != nil {
+		return 0, err
+	}
+
+	// the database was removed or flushed, IDs start again at 1
+	if cursor > maxID {
+		log.WithFields(log.Fields{
+			"cursor_file": cursorFile,
+			"cursor":      cursor,
+			"max_id":      maxID,
+		}).Warning(formatLogString("Cursor is beyond the newest query, the query log was reset"))
+
+		cursor = 0
+	}
+
+	return cursor, nil
+}
+
+func writeQueryLogCursor(cursorFile string, cursor int64) error {
+	return writeFileAtomic(cursorFile, []byte(strconv.FormatInt(cursor, 10)+"\n"))
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	log "github.com/sirupsen/logrus"
+)
+
+func remoteWriter() {
+	var queue [][]byte
+	var backoff = config.RemoteWrite.minBackoff
+	var wait time.Duration
+
+	log.WithFields(log.Fields{
+		"remote_write_url": config.RemoteWrite.URL,
+		"interval":         config.RemoteWrite.interval.String(),
+		"queue_size":       config.RemoteWrite.QueueSize,
+	}).Info(formatLogString("Starting Prometheus remote_write"))
+
+	nextCollect := time.Now()
+
+	for {
+		if !time.Now().Before(nextCollect) {
+			nextCollect = nextCollect.Add(config.RemoteWrite.interval)
+			if nextCollect.Before(time.Now()) {
+				nextCollect = time.Now().Add(config.RemoteWrite.interval)
+			}
+
+			request, err := buildRemoteWriteRequest()
+			if err == nil {
+				queue = append(queue, request)
+			}
+
+			// bounded queue, discard the oldest data if the receiver is not available for a long time
+			if uint(len(queue)) > config.RemoteWrite.QueueSize {
+				log.WithFields(log.Fields{
+					"remote_write_url": config.RemoteWrite.URL,
+					"queue_size":       config.RemoteWrite.QueueSize,
+				}).Warning(formatLogString("remote_write queue is full, discarding oldest data"))
+
+				queue = queue[uint(len(queue))-config.RemoteWrite.QueueSize:]
+			}
+		}
+
+		retry := false
+		for len(queue) > 0 {
+			var err error
+
+			retry, err = sendRemoteWriteRequest(queue[0])
+			if err != nil && retry {
+				break
+			}
+
+			// either sent or rejected by the receiver, in both cases the data will not be sent again
+			queue = queue[1:]
+		}
+
+		if retry {
+			wait = backoff
+			backoff *= 2
+			if backoff > config.RemoteWrite.maxBackoff {
+				backoff = config.RemoteWrite.maxBackoff
+			}
+		} else {
+			backoff = config.RemoteWrite.minBackoff
+			wait = time.Until(nextCollect)
+		}
+
+		if wait > time.Until(nextCollect) {
+			wait = time.Until(nextCollect)
+		}
+		time.Sleep(wait)
+	}
+}
+
+// buildRemoteWriteRequest - fetch current statistics and encode them as snappy compressed WriteRequest
+func buildRemoteWriteRequest() ([]byte, error) {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return nil, err
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	samples, err := parsePrometheusData(generatePrometheusData(rawsum, qtypes))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error(formatLogString("Can't parse generated Prometheus data"))
+
+		return nil, err
+	}
+
+	return snappy.Encode(nil, encodeRemoteWriteRequest(samples, time.Now().UnixNano()/1e+06)), nil
+}
+
+func encodeRemoteWriteRequest(samples []PrometheusSample, timestamp int64) []byte {
+	var request []byte
+
+	for _, sample := range samples {
+		var series []byte
+
+		labels := map[string]string{
+			"__name__": sample.Name,
+		}
+		for name, value := range config.RemoteWrite.externalLabels {
+			labels[name] = value
+		}
+		// labels of the sample take precedence over external labels
+		for name, value := range sample.Labels {
+			labels[name] = value
+		}
+
+		// remote_write requires labels sorted by name
+		for _, name := range sortedLabelNames(labels) {
+			var label []byte
+			label = protobufAppendString(label, 1, name)
+			label = protobufAppendString(label, 2, labels[name])
+			series = protobufAppendBytes(series, 1, label)
+		}
+
+		var _sample []byte
+		_sample = protobufAppendDouble(_sample, 1, sample.Value)
+		_sample = protobufAppendInt64(_sample, 2, timestamp)
+		series = protobufAppendBytes(series, 2, _sample)
+
+		request = protobufAppendBytes(request, 1, series)
+	}
+
+	return request
+}
+
+// sendRemoteWriteRequest - send WriteRequest to receiver, returns true if the request should be sent again later
+func sendRemoteWriteRequest(payload []byte) (bool, error) {
+	header := map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	}
+
+	if config.RemoteWrite.BearerToken != "" {
+		header["Authorization"] = "Bearer " + config.RemoteWrite.BearerToken
+	} else if config.RemoteWrite.Username != "" {
+		header["Authorization"] = basicAuthHeader(config.RemoteWrite.Username, config.RemoteWrite.Password)
+	}
+
+	result, err := httpRequest(config.RemoteWrite.URL, "POST", header, payload, config.RemoteWrite.InsecureSSL, config.RemoteWrite.CAFile, config.RemoteWrite.timeout)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"remote_write_url": config.RemoteWrite.URL,
+			"error":            err.Error(),
+		}).Error(formatLogString("Can't send data to remote_write receiver"))
+
+		return true, err
+	}
+
+	if result.StatusCode >= 200 && result.StatusCode < 300 {
+		return false, nil
+	}
+
+	log.WithFields(log.Fields{
+		"remote_write_url": config.RemoteWrite.URL,
+		"status_code":      result.StatusCode,
+		"status":           result.Status,
+		"response":         strings.TrimSpace(string(result.Content)),
+	}).Error(formatLogString("Unexpected HTTP status from remote_write receiver"))
+
+	err = fmt.Errorf("Unexpected HTTP status from remote_write receiver: %s", result.Status)
+
+	// client errors will not be fixed by sending the data again
+	if result.StatusCode < 500 && result.StatusCode != http.StatusTooManyRequests {
+		return false, err
+	}
+
+	return true, err
+}
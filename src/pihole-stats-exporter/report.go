@@ -0,0 +1,841 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// status codes of the FTL database for queries answered from the cache or by an upstream server
+var queryLogCachedStatus = map[int]bool{3: true, 17: true}
+var queryLogForwardedStatus = map[int]bool{2: true, 12: true, 13: true}
+
+var cronMonthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+var cronWeekdayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+var cronShortcuts = map[string]string{
+	"@daily":  "0 0 * * *",
+	"@weekly": "0 0 * * 0",
+	"@hourly": "0 * * * *",
+}
+
+var reportTemplateFunctions = map[string]interface{}{
+	"number": formatReportNumber,
+	"percent": func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 1, 64) + " %"
+	},
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04")
+	},
+	// pass title and items to the list template
+	"dict": func(values ...interface{}) map[string]interface{} {
+		var result = make(map[string]interface{})
+		for i := 0; i+1 < len(values); i += 2 {
+			result[fmt.Sprint(values[i])] = values[i+1]
+		}
+		return result
+	},
+}
+
+var reportHTMLTemplate = htmltemplate.Must(htmltemplate.New("report.html").Funcs(reportTemplateFunctions).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Name }} report for {{ .Instance }}</title>
+</head>
+<body style="font-family: Helvetica, Arial, sans-serif; font-size: 14px; color: #222;">
+<h2 style="margin-bottom: 0;">PiHole {{ .Period }} report for {{ .Instance }}</h2>
+<p style="color: #666; margin-top: 4px;">{{ date .From }} - {{ date .To }} ({{ .Source }})</p>
+{{ range .Errors }}<p style="color: #b00;">{{ . }}</p>
+{{ end -}}
+<table cellpadding="4" style="border-collapse: collapse;">
+<tr><td>Total queries</td><td align="right"><b>{{ number .Total }}</b></td></tr>
+<tr><td>Blocked queries</td><td align="right"><b>{{ number .Blocked }}</b></td></tr>
+<tr><td>Block ratio</td><td align="right"><b>{{ percent .BlockRatio }}</b></td></tr>
+<tr><td>Answered from cache</td><td align="right">{{ number .Cached }}</td></tr>
+<tr><td>Forwarded</td><td align="right">{{ number .Forwarded }}</td></tr>
+<tr><td>Unique domains</td><td align="right">{{ number .UniqueDomains }}</td></tr>
+<tr><td>Unique clients</td><td align="right">{{ number .UniqueClients }}</td></tr>
+<tr><td>Blocking</td><td align="right">{{ .Blocking }}</td></tr>
+<tr><td>Domains on blocklists</td><td align="right">{{ number .DomainsBeingBlocked }}</td></tr>
+<tr><td>Gravity last updated</td><td align="right">{{ if .GravityUpdated.IsZero }}unknown{{ else }}{{ date .GravityUpdated }} ({{ .GravityAgeDays }} days ago){{ end }}</td></tr>
+</table>
+{{ template "list" (dict "Title" "Top blocked domains" "Items" .TopBlocked) }}
+{{ template "list" (dict "Title" "Top permitted domains" "Items" .TopDomains) }}
+{{ template "list" (dict "Title" "Top clients" "Items" .TopClients) }}
+{{ template "list" (dict "Title" "Upstream servers" "Items" .Upstreams) }}
+<p style="color: #999; font-size: 12px;">Generated by {{ .Name }} {{ .Version }} at {{ date .Generated }} for {{ .Upstream }}</p>
+</body>
+</html>
+{{ define "list" }}{{ if .Items }}<h3>{{ .Title }}</h3>
+<table cellpadding="4" style="border-collapse: collapse;">
+{{ range .Items }}<tr><td>{{ .Name }}</td><td align="right">{{ if .Count }}{{ number .Count }}{{ end }}</td><td align="right" style="color: #666;">{{ percent .Share }}</td></tr>
+{{ end }}</table>{{ end }}{{ end }}
+`))
+
+var reportTextTemplate = template.Must(template.New("report.txt").Funcs(reportTemplateFunctions).Parse(`PiHole {{ .Period }} report for {{ .Instance }}
+{{ date .From }} - {{ date .To }} ({{ .Source }})
+{{- range .Errors }}
+{{ . }}
+{{- end }}
+
+Total queries          {{ printf "%15s" (number .Total) }}
+Blocked queries        {{ printf "%15s" (number .Blocked) }}
+Block ratio            {{ printf "%15s" (percent .BlockRatio) }}
+Answered from cache    {{ printf "%15s" (number .Cached) }}
+Forwarded              {{ printf "%15s" (number .Forwarded) }}
+Unique domains         {{ printf "%15s" (number .UniqueDomains) }}
+Unique clients         {{ printf "%15s" (number .UniqueClients) }}
+Blocking               {{ printf "%15s" .Blocking }}
+Domains on blocklists  {{ printf "%15s" (number .DomainsBeingBlocked) }}
+Gravity last updated   {{ if .GravityUpdated.IsZero }}unknown{{ else }}{{ date .GravityUpdated }} ({{ .GravityAgeDays }} days ago){{ end }}
+{{ template "list" (dict "Title" "Top blocked domains" "Items" .TopBlocked) }}
+{{- template "list" (dict "Title" "Top permitted domains" "Items" .TopDomains) }}
+{{- template "list" (dict "Title" "Top clients" "Items" .TopClients) }}
+{{- template "list" (dict "Title" "Upstream servers" "Items" .Upstreams) }}
+--
+Generated by {{ .Name }} {{ .Version }} at {{ date .Generated }} for {{ .Upstream }}
+{{ define "list" }}{{ if .Items }}
+{{ .Title }}
+{{ range .Items }}  {{ printf "%-50s" .Name }} {{ if .Count }}{{ printf "%12s" (number .Count) }}{{ else }}{{ printf "%12s" "" }}{{ end }} {{ printf "%8s" (percent .Share) }}
+{{ end }}{{ end }}{{ end }}`))
+
+// runReport - create the report and send it by email or write it to a file
+func runReport(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	configFile := flags.String("config", "", "Path to configuration file")
+	dryRun := flags.Bool("dry-run", false, "Write the report to a file instead of sending it")
+	output := flags.String("output", defaultReportOutput, "File for the HTML report in dry-run mode")
+	textOutput := flags.String("text-output", "", "File for the plain text report in dry-run mode")
+	period := flags.String("period", "", "Period of the report")
+
+	flags.SetOutput(os.Stdout)
+	flags.Usage = func() {
+		fmt.Printf(reportHelpText, name, defaultReportOutput)
+	}
+
+	err := flags.Parse(args)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) > 0 {
+		fmt.Fprintln(os.Stderr, "Trailing arguments")
+		return 1
+	}
+
+	if *configFile == "" {
+		fmt.Fprintln(os.Stderr, "Path to configuration file (--config) is mandatory")
+		return 1
+	}
+
+	config, err = parseConfigurationFile(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can't parse configuration file: "+err.Error())
+		return 1
+	}
+
+	if *period == "" {
+		*period = config.Report.Period
+	}
+	if *period != "daily" && *period != "weekly" {
+		fmt.Fprintln(os.Stderr, "Invalid period "+*period+", must be daily or weekly")
+		return 1
+	}
+	if *period == "weekly" && !config.QueryLog.enabled {
+		fmt.Fprintln(os.Stderr, "Weekly reports require the query log, database in section querylog is not set")
+		return 1
+	}
+
+	if !*dryRun && !config.Report.enabled {
+		fmt.Fprintln(os.Stderr, "SMTP server (smtp_server) in section report is not set, use --dry-run to write the report to a file")
+		return 1
+	}
+
+	data := collectReport(*period, time.Now())
+
+	html, text, err := renderReport(data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can't render report: "+err.Error())
+		return 1
+	}
+
+	if !*dryRun {
+		err = sendReportMail(buildReportMail(data, html, text, time.Now()))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Can't send report: "+err.Error())
+			return 1
+		}
+		return 0
+	}
+
+	err = ioutil.WriteFile(*output, html, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can't write report: "+err.Error())
+		return 1
+	}
+
+	if *textOutput != "" {
+		err = ioutil.WriteFile(*textOutput, text, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Can't write report: "+err.Error())
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func reportScheduler() {
+	log.WithFields(log.Fields{
+		"period":      config.Report.Period,
+		"schedule":    config.Report.Schedule,
+		"smtp_server": config.Report.address,
+		"to":          strings.Join(config.Report.to, ","),
+		"next_report": config.Report.schedule.next(time.Now()).Format(time.RFC3339),
+	}).Info(formatLogString("Starting scheduled report"))
+
+	for {
+		time.Sleep(time.Until(config.Report.schedule.next(time.Now())))
+
+		data := collectReport(config.Report.Period, time.Now())
+
+		html, text, err := renderReport(data)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error(formatLogString("Can't render report"))
+
+			continue
+		}
+
+		err = sendReportMail(buildReportMail(data, html, text, time.Now()))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"smtp_server": config.Report.address,
+				"to":          strings.Join(config.Report.to, ","),
+				"error":       err.Error(),
+			}).Error(formatLogString("Can't send report"))
+
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"smtp_server": config.Report.address,
+			"to":          strings.Join(config.Report.to, ","),
+			"from":        data.From.Format(time.RFC3339),
+			"until":       data.To.Format(time.RFC3339),
+			"next_report": config.Report.schedule.next(time.Now()).Format(time.RFC3339),
+		}).Info(formatLogString("Report sent"))
+	}
+}
+
+// collectReport - the query log covers the last complete days, without it only the values since midnight are available
+func collectReport(period string, now time.Time) ReportData {
+	var data = ReportData{
+		Name:      name,
+		Version:   version,
+		Instance:  config.PiHole.Instance,
+		Upstream:  config.PiHole.URL,
+		Period:    period,
+		Generated: now,
+		Blocking:  "unknown",
+	}
+
+	rawsum, rawsumErr := getPiHoleRawSummary()
+	if rawsumErr != nil {
+		data.Errors = append(data.Errors, "Can't fetch summary from PiHole server: "+rawsumErr.Error())
+	} else {
+		data.Blocking = rawsum.Status
+		data.DomainsBeingBlocked = rawsum.DomainsBeingBlocked
+
+		if rawsum.GravityLastUpdated.FileExists {
+			data.GravityUpdated = time.Unix(int64(rawsum.GravityLastUpdated.Absolute), 0)
+			data.GravityAgeDays = int64(now.Sub(data.GravityUpdated).Hours() / 24)
+		}
+	}
+
+	if config.QueryLog.enabled {
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		data.To = midnight
+		data.From = midnight.AddDate(0, 0, -1)
+		if period == "weekly" {
+			data.From = midnight.AddDate(0, 0, -7)
+		}
+		data.Source = "query log"
+
+		err := collectQueryLogReport(&data)
+		if err == nil {
+			return data
+		}
+
+		log.WithFields(log.Fields{
+			"database": config.QueryLog.Database,
+			"error":    err.Error(),
+		}).Error(formatLogString("Can't read query log for the report, using the values of the PiHole API"))
+
+		data.Errors = append(data.Errors, "Can't read query log: "+err.Error())
+	}
+
+	data.From = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	data.To = now
+	data.Source = "PiHole API, values since midnight"
+
+	if rawsumErr == nil {
+		collectAPIReport(&data, rawsum)
+	}
+
+	return data
+}
+
+func collectQueryLogReport(data *ReportData) error {
+	db, err := openQueryLog()
+	if err != nil {
+		return err
+	}
+
+	from := data.From.Unix()
+	to := data.To.Unix()
+	blocked := sqlStatusList(queryLogBlockedStatus)
+
+	err = db.QueryRow("SELECT COUNT(*), "+
+		"COALESCE(SUM(CASE WHEN status IN ("+blocked+") THEN 1 ELSE 0 END), 0), "+
+		"COALESCE(SUM(CASE WHEN status IN ("+sqlStatusList(queryLogCachedStatus)+") THEN 1 ELSE 0 END), 0), "+
+		"COALESCE(SUM(CASE WHEN status IN ("+sqlStatusList(queryLogForwardedStatus)+") THEN 1 ELSE 0 END), 0), "+
+		"COUNT(DISTINCT domain), COUNT(DISTINCT client) "+
+		"FROM queries WHERE timestamp >= ? AND timestamp < ?", from, to).Scan(&data.Total, &data.Blocked, &data.Cached, &data.Forwarded, &data.UniqueDomains, &data.UniqueClients)
+	if err != nil {
+		return err
+	}
+
+	if data.Total > 0 {
+		data.BlockRatio = 100.0 * float64(data.Blocked) / float64(data.Total)
+	}
+
+	data.TopBlocked, err = queryLogReportItems("SELECT domain, COUNT(*) AS c FROM queries WHERE timestamp >= ? AND timestamp < ? AND status IN ("+blocked+") GROUP BY domain ORDER BY c DESC, domain LIMIT ?", from, to, data.Total)
+	if err != nil {
+		return err
+	}
+
+	data.TopDomains, err = queryLogReportItems("SELECT domain, COUNT(*) AS c FROM queries WHERE timestamp >= ? AND timestamp < ? AND status NOT IN ("+blocked+") GROUP BY domain ORDER BY c DESC, domain LIMIT ?", from, to, data.Total)
+	if err != nil {
+		return err
+	}
+
+	data.TopClients, err = queryLogReportItems("SELECT client, COUNT(*) AS c FROM queries WHERE timestamp >= ? AND timestamp < ? GROUP BY client ORDER BY c DESC, client LIMIT ?", from, to, data.Total)
+	if err != nil {
+		return err
+	}
+
+	// same layout as the forward destinations of the PiHole API: upstream servers, blocked and cached queries
+	upstreams, err := queryLogReportItems("SELECT forward, COUNT(*) AS c FROM queries WHERE timestamp >= ? AND timestamp < ? AND status IN ("+sqlStatusList(queryLogForwardedStatus)+") AND forward IS NOT NULL AND forward != '' GROUP BY forward ORDER BY c DESC, forward LIMIT ?", from, to, data.Total)
+	if err != nil {
+		return err
+	}
+
+	data.Upstreams = appendReportItem(nil, "blocked", data.Blocked, data.Total)
+	data.Upstreams = appendReportItem(data.Upstreams, "cached", data.Cached, data.Total)
+	data.Upstreams = append(data.Upstreams, upstreams...)
+	sortReportItems(data.Upstreams)
+
+	return nil
+}
+
+func queryLogReportItems(query string, from int64, to int64, total uint64) ([]ReportItem, error) {
+	var result []ReportItem
+
+	db, err := openQueryLog()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(query, from, to, config.Report.Top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var count uint64
+
+		err = rows.Scan(&name, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		result = appendReportItem(result, name, count, total)
+	}
+
+	return result, rows.Err()
+}
+
+// collectAPIReport - top lists and forward destinations require the auth token, missing data is noted in the report
+func collectAPIReport(data *ReportData, rawsum PiHoleRawSummary) {
+	data.Total = rawsum.DNSQueriesToday
+	data.Blocked = rawsum.AdsBlockedToday
+	data.BlockRatio = rawsum.AdsPercentageToday
+	data.Cached = rawsum.QueriesCached
+	data.Forwarded = rawsum.QueriesForwarded
+	data.UniqueDomains = rawsum.UniqueDomains
+	data.UniqueClients = rawsum.UniqueClients
+
+	top, err := getPiHoleTopItems(config.Report.Top)
+	if err != nil {
+		data.Errors = append(data.Errors, "Can't fetch top domains from PiHole server, check auth in section pihole: "+err.Error())
+	} else {
+		for domain, count := range top.TopQueries {
+			data.TopDomains = appendReportItem(data.TopDomains, domain, count, data.Total)
+		}
+		for domain, count := range top.TopAds {
+			data.TopBlocked = appendReportItem(data.TopBlocked, domain, count, data.Total)
+		}
+	}
+
+	sources, err := getPiHoleQuerySources(config.Report.Top)
+	if err != nil {
+		data.Errors = append(data.Errors, "Can't fetch top clients from PiHole server, check auth in section pihole: "+err.Error())
+	} else {
+		for client, count := range sources.TopSources {
+			data.TopClients = appendReportItem(data.TopClients, getPiHoleItemName(client), count, data.Total)
+		}
+	}
+
+	fwd, err := getPiHoleForwardDestinations()
+	if err != nil {
+		data.Errors = append(data.Errors, "Can't fetch upstream servers from PiHole server, check auth in section pihole: "+err.Error())
+	} else {
+		for upstream, share := range fwd.ForwardDestinations {
+			data.Upstreams = append(data.Upstreams, ReportItem{
+				Name:  getPiHoleItemName(upstream),
+				Share: share,
+			})
+		}
+	}
+
+	for _, items := range [][]ReportItem{data.TopDomains, data.TopBlocked, data.TopClients, data.Upstreams} {
+		sortReportItems(items)
+	}
+}
+
+// getPiHoleItemName - the PiHole API uses "name|address" as key, the name is empty if it can't be resolved
+func getPiHoleItemName(key string) string {
+	fields := strings.SplitN(key, "|", 2)
+	if len(fields) == 2 && fields[0] != "" && fields[0] != fields[1] {
+		return fields[0] + " (" + fields[1] + ")"
+	}
+	if len(fields) == 2 {
+		return fields[1]
+	}
+	return key
+}
+
+func appendReportItem(items []ReportItem, name string, count uint64, total uint64) []ReportItem {
+	var share float64
+
+	if total > 0 {
+		share = 100.0 * float64(count) / float64(total)
+	}
+
+	return append(items, ReportItem{
+		Name:  name,
+		Count: count,
+		Share: share,
+	})
+}
+
+func sortReportItems(items []ReportItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Share != items[j].Share {
+			return items[i].Share > items[j].Share
+		}
+		return items[i].Name < items[j].Name
+	})
+}
+
+func sqlStatusList(statuses map[int]bool) string {
+	var codes []int
+	var result []string
+
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	for _, code := range codes {
+		result = append(result, strconv.Itoa(code))
+	}
+
+	return strings.Join(result, ",")
+}
+
+// formatReportNumber - decimal number with thousands separator
+func formatReportNumber(n uint64) string {
+	s := strconv.FormatUint(n, 10)
+
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+
+	return s
+}
+
+func renderReport(data ReportData) ([]byte, []byte, error) {
+	var html bytes.Buffer
+	var text bytes.Buffer
+
+	err := reportHTMLTemplate.Execute(&html, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = reportTextTemplate.Execute(&text, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return html.Bytes(), text.Bytes(), nil
+}
+
+// buildReportMail - multipart/alternative message with the plain text and the HTML report
+func buildReportMail(data ReportData, html []byte, text []byte, now time.Time) []byte {
+	var body bytes.Buffer
+	var message bytes.Buffer
+
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{contentType: "text/plain; charset=utf-8", content: text},
+		{contentType: "text/html; charset=utf-8", content: html},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		w, _ := writer.CreatePart(header)
+		qp := quotedprintable.NewWriter(w)
+		qp.Write(part.content)
+		qp.Close()
+	}
+	writer.Close()
+
+	interval := data.From.Format("2006-01-02")
+	if data.To.Sub(data.From) > 24*time.Hour {
+		interval += " - " + data.To.Add(-time.Second).Format("2006-01-02")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "localhost"
+	}
+
+	fmt.Fprintf(&message, "From: %s\r\n", config.Report.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(config.Report.to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", config.Report.Subject+" for "+data.Instance+", "+interval))
+	fmt.Fprintf(&message, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Message-ID: <%d.%d.%s@%s>\r\n", now.UnixNano(), os.Getpid(), name, hostname)
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=\"%s\"\r\n", writer.Boundary())
+	fmt.Fprintf(&message, "\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes()
+}
+
+func sendReportMail(message []byte) error {
+	var conn net.Conn
+
+	sender, err := mail.ParseAddress(config.Report.From)
+	if err != nil {
+		return err
+	}
+
+	recipients, err := mail.ParseAddressList(strings.Join(config.Report.to, ","))
+	if err != nil {
+		return err
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName:         config.Report.host,
+		InsecureSkipVerify: config.Report.InsecureSSL,
+	}
+
+	if config.Report.CAFile != "" {
+		cadata, err := ioutil.ReadFile(config.Report.CAFile)
+		if err != nil {
+			return err
+		}
+
+		cacerts := x509.NewCertPool()
+		if !cacerts.AppendCertsFromPEM(cadata) {
+			return fmt.Errorf("Can't append CA data to CA pool")
+		}
+
+		tlsCfg.RootCAs = cacerts
+	}
+
+	dialer := &net.Dialer{
+		Timeout: config.Report.timeout,
+	}
+
+	if config.Report.SMTPTLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", config.Report.address, tlsCfg)
+	} else {
+		conn, err = dialer.Dial("tcp", config.Report.address)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the timeout covers the whole SMTP session
+	err = conn.SetDeadline(time.Now().Add(config.Report.timeout))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, config.Report.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	hostname, err := os.Hostname()
+	if err == nil && hostname != "" {
+		err = client.Hello(hostname)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.Report.SMTPTLS == "starttls" {
+		supported, _ := client.Extension("STARTTLS")
+		if !supported {
+			return fmt.Errorf("SMTP server %s doesn't support STARTTLS", config.Report.address)
+		}
+
+		err = client.StartTLS(tlsCfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.Report.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", config.Report.Username, config.Report.Password, config.Report.host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(sender.Address)
+	if err != nil {
+		return err
+	}
+
+	for _, rcpt := range recipients {
+		err = client.Rcpt(rcpt.Address)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(message)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// parseCronSchedule - minute, hour, day of month, month and day of week like crontab(5), evaluated in local time
+func parseCronSchedule(s string) (CronSchedule, error) {
+	var schedule CronSchedule
+	var err error
+
+	spec := strings.TrimSpace(s)
+	if shortcut, found := cronShortcuts[strings.ToLower(spec)]; found {
+		spec = shortcut
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return schedule, fmt.Errorf("Invalid schedule %s, five fields (minute, hour, day of month, month, day of week) are required", s)
+	}
+
+	schedule.minutes, err = parseCronField(fields[0], 0, 59, nil)
+	if err != nil {
+		return schedule, fmt.Errorf("Invalid minute in schedule %s: %s", s, err.Error())
+	}
+
+	schedule.hours, err = parseCronField(fields[1], 0, 23, nil)
+	if err != nil {
+		return schedule, fmt.Errorf("Invalid hour in schedule %s: %s", s, err.Error())
+	}
+
+	schedule.days, err = parseCronField(fields[2], 1, 31, nil)
+	if err != nil {
+		return schedule, fmt.Errorf("Invalid day of month in schedule %s: %s", s, err.Error())
+	}
+
+	schedule.months, err = parseCronField(fields[3], 1, 12, cronMonthNames)
+	if err != nil {
+		return schedule, fmt.Errorf("Invalid month in schedule %s: %s", s, err.Error())
+	}
+
+	// 7 is Sunday too
+	schedule.weekdays, err = parseCronField(fields[4], 0, 7, cronWeekdayNames)
+	if err != nil {
+		return schedule, fmt.Errorf("Invalid day of week in schedule %s: %s", s, err.Error())
+	}
+	if schedule.weekdays[7] {
+		schedule.weekdays[0] = true
+	}
+
+	schedule.anyDay = strings.HasPrefix(fields[2], "*")
+	schedule.anyWeekday = strings.HasPrefix(fields[4], "*")
+
+	if schedule.next(time.Now()).IsZero() {
+		return schedule, fmt.Errorf("Schedule %s never matches", s)
+	}
+
+	return schedule, nil
+}
+
+// parseCronField - comma separated list of *, values and ranges, each with an optional step
+func parseCronField(field string, min int, max int, names map[string]int) (map[int]bool, error) {
+	var result = make(map[int]bool)
+
+	parseValue := func(s string) (int, error) {
+		if v, found := names[strings.ToLower(s)]; found {
+			return v, nil
+		}
+
+		v, err := strconv.Atoi(s)
+		if err != nil || v < min || v > max {
+			return 0, fmt.Errorf("%s is not a number between %d and %d", s, min, max)
+		}
+		return v, nil
+	}
+
+	for _, item := range strings.Split(field, ",") {
+		var err error
+		var step = 1
+		var first = min
+		var last = max
+
+		if strings.Contains(item, "/") {
+			parts := strings.SplitN(item, "/", 2)
+			item = parts[0]
+
+			step, err = strconv.Atoi(parts[1])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("Invalid step %s", parts[1])
+			}
+		}
+
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+
+			first, err = parseValue(bounds[0])
+			if err != nil {
+				return nil, err
+			}
+
+			last, err = parseValue(bounds[1])
+			if err != nil {
+				return nil, err
+			}
+
+			if first > last {
+				return nil, fmt.Errorf("Invalid range %s", item)
+			}
+		default:
+			first, err = parseValue(item)
+			if err != nil {
+				return nil, err
+			}
+
+			// a single value with a step (e.g. 5/15) starts at the value
+			if step == 1 {
+				last = first
+			}
+		}
+
+		for v := first; v <= last; v += step {
+			result[v] = true
+		}
+	}
+
+	return result, nil
+}
+
+// match - if day of month and day of week are restricted, either of them matches (like cron)
+func (s CronSchedule) match(t time.Time) bool {
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] {
+		return false
+	}
+
+	day := s.days[t.Day()]
+	weekday := s.weekdays[int(t.Weekday())]
+
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+// next - first matching minute after t, a schedule that never matches (e.g. 30th of February) returns the zero time
+func (s CronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// four years cover all combinations of days, months and leap years
+	for limit := t.AddDate(4, 0, 1); t.Before(limit); t = t.Add(time.Minute) {
+		if s.match(t) {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	for _, s := range []string{"0 7 * * *", "0 7 * * 1", "30 6 * * mon-fri", "*/15 8-18 * jan,jul 0-6/2", "@daily", "@weekly"} {
+		_, err := parseCronSchedule(s)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", s, err)
+		}
+	}
+
+	for _, s := range []string{"", "0 7 * *", "60 7 * * *", "0 24 * * *", "0 7 0 * *", "0 7 * 13 *", "0 7 * * 8", "0 7 * * foo", "5-1 * * * *", "*/0 * * * *", "0 0 30 2 *"} {
+		_, err := parseCronSchedule(s)
+		if err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// Monday, 19th of October 2026
+	monday := time.Date(2026, time.October, 19, 8, 0, 0, 0, time.Local)
+
+	for _, c := range []struct {
+		schedule string
+		from     time.Time
+		expected time.Time
+	}{
+		{schedule: "0 7 * * *", from: monday, expected: time.Date(2026, time.October, 20, 7, 0, 0, 0, time.Local)},
+		{schedule: "0 7 * * 1", from: monday, expected: time.Date(2026, time.October, 26, 7, 0, 0, 0, time.Local)},
+		{schedule: "0 7 * * 1", from: monday.Add(-2 * time.Hour), expected: time.Date(2026, time.October, 19, 7, 0, 0, 0, time.Local)},
+		{schedule: "0 7 * * sun", from: monday, expected: time.Date(2026, time.October, 25, 7, 0, 0, 0, time.Local)},
+		{schedule: "0 7 * * 7", from: monday, expected: time.Date(2026, time.October, 25, 7, 0, 0, 0, time.Local)},
+		{schedule: "*/20 * * * *", from: monday.Add(5 * time.Minute), expected: monday.Add(20 * time.Minute)},
+		// day of month and day of week are or-ed like cron, the 1st of November is a Sunday
+		{schedule: "0 0 1 * fri", from: monday, expected: time.Date(2026, time.October, 23, 0, 0, 0, 0, time.Local)},
+		{schedule: "0 0 29 2 *", from: monday, expected: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.Local)},
+	} {
+		schedule, err := parseCronSchedule(c.schedule)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", c.schedule, err)
+		}
+
+		next := schedule.next(c.from)
+		if !next.Equal(c.expected) {
+			t.Errorf("Next time of %s after %s is %s, expected %s", c.schedule, c.from, next, c.expected)
+		}
+	}
+}
+
+func setupReportTest(address string) ReportData {
+	config = &Configuration{
+		Report: ReportConfiguration{
+			From:    "PiHole <pihole@example.com>",
+			Subject: "PiHole report",
+			SMTPTLS: "none",
+		},
+	}
+	config.Report.to = []string{"admin@example.com", "Ops Team <ops@example.com>"}
+	config.Report.address = address
+	config.Report.host, _, _ = net.SplitHostPort(address)
+	config.Report.timeout = 5 * time.Second
+
+	from := time.Date(2026, time.October, 12, 0, 0, 0, 0, time.UTC)
+	return ReportData{
+		Instance: "pihole.example.com",
+		Period:   "weekly",
+		From:     from,
+		To:       from.AddDate(0, 0, 7),
+	}
+}
+
+func TestBuildReportMail(t *testing.T) {
+	data := setupReportTest("127.0.0.1:25")
+	text := []byte("Blocked: 12.34% of all queries, a line longer than seventy six characters has to be wrapped by quoted-printable\n")
+	html := []byte("<p>Blocked: <b>12.34%</b> – äöü</p>\n")
+
+	msg, err := mail.ReadMessage(bytes.NewReader(buildReportMail(data, html, text, time.Now())))
+	if err != nil {
+		t.Fatalf("Can't parse message: %s", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("Can't decode subject: %s", err)
+	}
+	if subject != "PiHole report for pihole.example.com, 2026-10-12 - 2026-10-18" {
+		t.Errorf("Unexpected subject %q", subject)
+	}
+	if msg.Header.Get("To") != "admin@example.com, Ops Team <ops@example.com>" {
+		t.Errorf("Unexpected recipients %q", msg.Header.Get("To"))
+	}
+	if msg.Header.Get("MIME-Version") != "1.0" {
+		t.Errorf("MIME-Version is missing")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Unexpected content type %q", msg.Header.Get("Content-Type"))
+	}
+
+	// the preferred (HTML) part is the last one
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for _, expected := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{contentType: "text/plain; charset=utf-8", content: text},
+		{contentType: "text/html; charset=utf-8", content: html},
+	} {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			t.Fatalf("Can't read part %s: %s", expected.contentType, err)
+		}
+		if part.Header.Get("Content-Type") != expected.contentType {
+			t.Errorf("Unexpected content type %q, expected %q", part.Header.Get("Content-Type"), expected.contentType)
+		}
+
+		raw, _ := ioutil.ReadAll(part)
+		for _, line := range strings.Split(string(raw), "\r\n") {
+			if len(line) > 76 {
+				t.Errorf("Line %q of part %s is longer than 76 characters", line, expected.contentType)
+			}
+		}
+
+		content, err := ioutil.ReadAll(quotedprintable.NewReader(bytes.NewReader(raw)))
+		if err != nil {
+			t.Fatalf("Can't decode part %s: %s", expected.contentType, err)
+		}
+		// line breaks of text are encoded as CRLF
+		if !bytes.Equal(content, bytes.Replace(expected.content, []byte("\n"), []byte("\r\n"), -1)) {
+			t.Errorf("Content of part %s is %q, expected %q", expected.contentType, content, expected.content)
+		}
+	}
+
+	_, err = reader.NextPart()
+	if err == nil {
+		t.Errorf("Unexpected third part")
+	}
+}
+
+// smtpSink - accepts a single message without authentication and TLS
+func smtpSink(listener net.Listener, result chan<- []string) {
+	var session []string
+
+	conn, err := listener.Accept()
+	if err != nil {
+		close(result)
+		return
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	reply := func(s string) {
+		conn.Write([]byte(s + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP sink")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch command {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+			session = append(session, line)
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+
+			var data []string
+			for {
+				l, err := reader.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				data = append(data, l)
+			}
+			session = append(session, "DATA "+strings.Join(data, ""))
+			reply("250 OK queued")
+		case "QUIT":
+			reply("221 Bye")
+			result <- session
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+
+	result <- session
+}
+
+func TestSendReportMail(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Can't listen: %s", err)
+	}
+	defer listener.Close()
+
+	result := make(chan []string, 1)
+	go smtpSink(listener, result)
+
+	data := setupReportTest(listener.Addr().String())
+	message := buildReportMail(data, []byte("<p>report</p>\n"), []byte("report\n"), time.Now())
+
+	err = sendReportMail(message)
+	if err != nil {
+		t.Fatalf("Can't send report: %s", err)
+	}
+
+	var session []string
+	select {
+	case session = <-result:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("SMTP session didn't finish")
+	}
+
+	expected := []string{
+		"MAIL FROM:<pihole@example.com>",
+		"RCPT TO:<admin@example.com>",
+		"RCPT TO:<ops@example.com>",
+	}
+	var commands []string
+	var received string
+	for _, line := range session {
+		if strings.HasPrefix(line, "DATA ") {
+			received = strings.TrimPrefix(line, "DATA ")
+			continue
+		}
+		// the client may add parameters like BODY=8BITMIME
+		commands = append(commands, strings.SplitN(line, " BODY=", 2)[0])
+	}
+
+	if strings.Join(commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected SMTP commands %q, expected %q", commands, expected)
+	}
+
+	// the message is sent with CRLF line endings and dot stuffing, which the sink doesn't remove
+	if strings.Replace(received, "\r\n", "\n", -1) != strings.Replace(string(message), "\r\n", "\n", -1) {
+		t.Errorf("Received message differs from the sent message:\n%s", received)
+	}
+}
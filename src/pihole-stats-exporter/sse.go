@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// lower limit for the interval requested by the client, every client with an interval polls the PiHole server
+const sseMinInterval = 5 * time.Second
+
+var sseLock sync.Mutex
+var sseSubscribers uint
+
+// closed on shutdown of the HTTP server, otherwise the open streams would delay the shutdown
+var sseShutdown = make(chan bool)
+
+func sseExporter(response http.ResponseWriter, request *http.Request) {
+	var interval time.Duration
+	var lastEventID uint64
+	var hasLastEventID bool
+
+	log.WithFields(log.Fields{
+		"method":         request.Method,
+		"url":            request.URL.String(),
+		"protocol":       request.Proto,
+		"host":           request.Host,
+		"remote_address": request.RemoteAddr,
+		"headers":        fmt.Sprintf("%+v\n", request.Header),
+		"client_cert":    getClientIdentity(request),
+	}).Info(formatLogString("HTTP request from client received"))
+
+	response.Header().Add("X-Clacks-Overhead", "GNU Terry Pratchett")
+
+	flusher, ok := response.(http.Flusher)
+	if !ok {
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	_interval := request.URL.Query().Get("interval")
+	if _interval != "" {
+		seconds, err := strconv.ParseUint(_interval, 10, 32)
+		if err != nil || seconds == 0 {
+			response.WriteHeader(http.StatusBadRequest)
+			response.Write([]byte("Invalid interval\n"))
+			return
+		}
+
+		interval = time.Duration(seconds) * time.Second
+		if interval < sseMinInterval {
+			interval = sseMinInterval
+		}
+	}
+
+	// sent by the browser on reconnect, invalid values are ignored
+	_lastEventID := request.Header.Get("Last-Event-ID")
+	if _lastEventID != "" {
+		id, err := strconv.ParseUint(_lastEventID, 10, 64)
+		if err == nil {
+			lastEventID = id
+			hasLastEventID = true
+		}
+	}
+
+	sseLock.Lock()
+	if sseSubscribers >= config.Exporter.SSEMaxSubscribers {
+		sseLock.Unlock()
+
+		log.WithFields(log.Fields{
+			"remote_address":  request.RemoteAddr,
+			"max_subscribers": config.Exporter.SSEMaxSubscribers,
+		}).Warning(formatLogString("Maximal number of SSE subscribers reached, rejecting request"))
+
+		response.Header().Set("Retry-After", strconv.FormatUint(uint64(config.Exporter.PollInterval), 10))
+		response.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	sseSubscribers++
+	sseLock.Unlock()
+
+	defer func() {
+		sseLock.Lock()
+		sseSubscribers--
+		sseLock.Unlock()
+	}()
+
+	// the write timeout of the HTTP server would terminate the stream
+	http.NewResponseController(response).SetWriteDeadline(time.Time{})
+
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("X-Accel-Buffering", "no")
+	response.WriteHeader(http.StatusOK)
+
+	// reconnect delay for the client in milliseconds
+	fmt.Fprintf(response, "retry: %d\n\n", config.Exporter.pollInterval.Milliseconds())
+	flusher.Flush()
+
+	var updates chan PollSnapshot
+	var tick <-chan time.Time
+	var err error
+
+	if interval == 0 {
+		updates = subscribePoller()
+		defer unsubscribePoller(updates)
+
+		// the client already received the last snapshot before reconnecting
+		last := getLastPollSnapshot()
+		if last != nil && (!hasLastEventID || last.ID > lastEventID) {
+			err = writeSSEEvent(response, flusher, *last)
+		}
+	} else {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+
+		err = writeSSEEvent(response, flusher, PollSnapshot{ID: nextPollSnapshotID(), Document: generateJSONDocument()})
+	}
+
+	heartbeat := time.NewTicker(config.Exporter.sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for err == nil {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-sseShutdown:
+			return
+		case snapshot := <-updates:
+			err = writeSSEEvent(response, flusher, snapshot)
+		case <-tick:
+			err = writeSSEEvent(response, flusher, PollSnapshot{ID: nextPollSnapshotID(), Document: generateJSONDocument()})
+		case <-heartbeat.C:
+			// comments are ignored by the client but keep proxies from closing the connection
+			_, err = response.Write([]byte(": heartbeat\n\n"))
+			flusher.Flush()
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"remote_address": request.RemoteAddr,
+		"error":          err.Error(),
+	}).Info(formatLogString("SSE stream closed"))
+}
+
+func writeSSEEvent(response http.ResponseWriter, flusher http.Flusher, snapshot PollSnapshot) error {
+	payload, err := json.Marshal(snapshot.Document)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(response, "id: %d\nevent: stats\ndata: %s\n\n", snapshot.ID, payload)
+	if err != nil {
+		return err
+	}
+
+	flusher.Flush()
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var statsDConnection net.Conn
+
+// characters with a special meaning in the StatsD protocol
+var statsDNameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_", " ", "_")
+
+// DogStatsD splits tags at the first colon, so tag values may contain colons
+var statsDTagValueReplacer = strings.NewReplacer("|", "_", ",", "_", "\n", "_")
+
+func statsDSender() {
+	log.WithFields(log.Fields{
+		"statsd_address": config.StatsD.Address,
+		"protocol":       config.StatsD.Protocol,
+		"dogstatsd":      config.StatsD.DogStatsD,
+		"interval":       config.StatsD.interval.String(),
+	}).Info(formatLogString("Starting StatsD output"))
+
+	ticker := time.NewTicker(config.StatsD.interval)
+	for {
+		sendStatsD()
+		<-ticker.C
+	}
+}
+
+func sendStatsD() {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	samples, err := parsePrometheusData(generatePrometheusData(rawsum, qtypes))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error(formatLogString("Can't parse generated Prometheus data"))
+
+		return
+	}
+
+	packets := packStatsDLines(generateStatsDData(samples), config.StatsD.MTU)
+
+	err = writeStatsD(packets)
+	if err != nil {
+		// the receiver may have been restarted (e.g. new unix socket), try again with a new connection
+		closeStatsD()
+		err = writeStatsD(packets)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statsd_address": config.StatsD.Address,
+			"protocol":       config.StatsD.Protocol,
+			"error":          err.Error(),
+		}).Error(formatLogString("Can't send data to StatsD"))
+
+		closeStatsD()
+	}
+}
+
+// generateStatsDData - gauges from Prometheus samples, labels are mapped to DogStatsD tags or appended to the name
+func generateStatsDData(samples []PrometheusSample) []string {
+	var result []string
+	var globalTags []string
+
+	for _, name := range sortedLabelNames(config.StatsD.tags) {
+		globalTags = append(globalTags, statsDNameReplacer.Replace(name)+":"+statsDTagValueReplacer.Replace(config.StatsD.tags[name]))
+	}
+
+	for _, sample := range samples {
+		name := config.StatsD.Prefix + sample.Name
+		value := strconv.FormatFloat(sample.Value, 'f', -1, 64)
+
+		if config.StatsD.DogStatsD {
+			var tags []string
+			for _, label := range sortedLabelNames(sample.Labels) {
+				tags = append(tags, statsDNameReplacer.Replace(label)+":"+statsDTagValueReplacer.Replace(sample.Labels[label]))
+			}
+			tags = append(tags, globalTags...)
+			sort.Strings(tags)
+
+			line := statsDNameReplacer.Replace(name) + ":" + value + "|g"
+			if len(tags) > 0 {
+				line += "|#" + strings.Join(tags, ",")
+			}
+			result = append(result, line)
+			continue
+		}
+
+		// plain StatsD has no tags, the upstream is omitted because it is the same for all metrics
+		for _, label := range sortedLabelNames(sample.Labels) {
+			if label == "upstream" {
+				continue
+			}
+			name += "." + sample.Labels[label]
+		}
+		result = append(result, statsDNameReplacer.Replace(name)+":"+value+"|g")
+	}
+
+	return result
+}
+
+// packStatsDLines - combine lines into packets not exceeding the MTU
+func packStatsDLines(lines []string, mtu uint) [][]byte {
+	var result [][]byte
+	var packet []byte
+
+	for _, line := range lines {
+		if len(packet) > 0 && uint(len(packet)+1+len(line)) > mtu {
+			result = append(result, packet)
+			packet = nil
+		}
+
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+
+	if len(packet) > 0 {
+		result = append(result, packet)
+	}
+
+	return result
+}
+
+func writeStatsD(packets [][]byte) error {
+	var err error
+
+	if statsDConnection == nil {
+		statsDConnection, err = net.Dial(config.StatsD.Protocol, config.StatsD.Address)
+		if err != nil {
+			statsDConnection = nil
+			return err
+		}
+	}
+
+	for _, packet := range packets {
+		_, err = statsDConnection.Write(packet)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func closeStatsD() {
+	if statsDConnection != nil {
+		statsDConnection.Close()
+		statsDConnection = nil
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// private enterprise number reserved for documentation (RFC 5612), used as SD-ID of the structured data
+const syslogEnterpriseNumber = "32473"
+
+// severities of RFC 5424
+const (
+	syslogSeverityNotice        = 5
+	syslogSeverityInformational = 6
+)
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+var syslogConnection net.Conn
+var syslogTokens float64
+var syslogLastRefill time.Time
+
+var syslogSDReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+var cefHeaderReplacer = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+var cefExtensionReplacer = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+var leefReplacer = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ", "|", " ")
+
+func syslogSender() {
+	log.WithFields(log.Fields{
+		"syslog_server": config.Syslog.address,
+		"protocol":      config.Syslog.Protocol,
+		"format":        config.Syslog.Format,
+		"database":      config.QueryLog.Database,
+		"cursor_file":   config.Syslog.CursorFile,
+		"events":        config.Syslog.Events,
+		"rate_limit":    config.Syslog.RateLimit,
+		"interval":      config.Syslog.interval.String(),
+	}).Info(formatLogString("Starting forwarding of the query log to syslog"))
+
+	syslogTokens = float64(config.Syslog.RateLimit)
+	syslogLastRefill = time.Now()
+
+	ticker := time.NewTicker(config.Syslog.interval)
+	for {
+		sendSyslog()
+		<-ticker.C
+	}
+}
+
+// sendSyslog - forward all new queries, the cursor is moved to the last query written to the connection
+func sendSyslog() {
+	var sent uint64
+	var dropped uint64
+
+	cursor, err := readQueryLogCursor(config.Syslog.CursorFile)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"database":    config.QueryLog.Database,
+			"cursor_file": config.Syslog.CursorFile,
+			"error":       err.Error(),
+		}).Error(formatLogString("Can't get cursor of the query log"))
+
+		return
+	}
+
+	defer func() {
+		if dropped > 0 {
+			log.WithFields(log.Fields{
+				"syslog_server": config.Syslog.address,
+				"rate_limit":    config.Syslog.RateLimit,
+				"sent":          sent,
+				"dropped":       dropped,
+			}).Warning(formatLogString("Rate limit for syslog exceeded, queries were dropped"))
+		}
+	}()
+
+	for {
+		entries, err := readQueryLog(cursor, config.QueryLog.BatchSize)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"database": config.QueryLog.Database,
+				"cursor":   cursor,
+				"error":    err.Error(),
+			}).Error(formatLogString("Can't read query log"))
+
+			return
+		}
+
+		if len(entries) == 0 {
+			return
+		}
+
+		processed := cursor
+		for _, entry := range entries {
+			if !matchSyslogFilter(entry) {
+				processed = entry.ID
+				continue
+			}
+
+			if !takeSyslogToken() {
+				dropped++
+				processed = entry.ID
+				continue
+			}
+
+			message := formatSyslogMessage(entry, time.Now())
+
+			err = writeSyslog(message)
+			if err != nil {
+				// the connection may have been closed by the server, try again with a new connection
+				closeSyslog()
+				err = writeSyslog(message)
+			}
+
+			if err != nil {
+				log.WithFields(log.Fields{
+					"syslog_server": config.Syslog.address,
+					"protocol":      config.Syslog.Protocol,
+					"error":         err.Error(),
+				}).Error(formatLogString("Can't send query log to syslog"))
+
+				closeSyslog()
+				break
+			}
+
+			sent++
+			processed = entry.ID
+		}
+
+		if processed != cursor {
+			cursor = processed
+			err = writeQueryLogCursor(config.Syslog.CursorFile, cursor)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"cursor_file": config.Syslog.CursorFile,
+					"cursor":      cursor,
+					"error":       err.Error(),
+				}).Error(formatLogString("Can't write cursor of the query log"))
+
+				return
+			}
+		}
+
+		if cursor != entries[len(entries)-1].ID || uint(len(entries)) < config.QueryLog.BatchSize {
+			return
+		}
+	}
+}
+
+func matchSyslogFilter(entry QueryLogEntry) bool {
+	if config.Syslog.Events == "blocked" && !entry.Blocked {
+		return false
+	}
+
+	if len(config.Syslog.clientNets) > 0 || len(config.Syslog.clientNames) > 0 {
+		found := config.Syslog.clientNames[strings.ToLower(entry.Client)]
+
+		ip := net.ParseIP(entry.Client)
+		for _, n := range config.Syslog.clientNets {
+			if found {
+				break
+			}
+			found = ip != nil && n.Contains(ip)
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(config.Syslog.domains) > 0 {
+		domain := strings.ToLower(strings.TrimSuffix(entry.Domain, "."))
+		found := false
+
+		// a domain matches itself and all subdomains
+		for _, d := range config.Syslog.domains {
+			if domain == d || strings.HasSuffix(domain, "."+d) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// takeSyslogToken - token bucket, refilled with rate_limit tokens per second up to rate_limit tokens
+func takeSyslogToken() bool {
+	if config.Syslog.RateLimit == 0 {
+		return true
+	}
+
+	now := time.Now()
+	syslogTokens += now.Sub(syslogLastRefill).Seconds() * float64(config.Syslog.RateLimit)
+	if syslogTokens > float64(config.Syslog.RateLimit) {
+		syslogTokens = float64(config.Syslog.RateLimit)
+	}
+	syslogLastRefill = now
+
+	if syslogTokens < 1 {
+		return false
+	}
+
+	syslogTokens--
+	return true
+}
+
+// formatSyslogMessage - RFC 5424 message, the CEF and LEEF layouts replace structured data and message text
+func formatSyslogMessage(entry QueryLogEntry, now time.Time) string {
+	var severity = syslogSeverityInformational
+	var action = "allowed"
+	var data string
+	var message string
+
+	if entry.Blocked {
+		severity = syslogSeverityNotice
+		action = "blocked"
+	}
+
+	switch config.Syslog.Format {
+	case "cef":
+		data = "-"
+		message = formatCEFMessage(entry, action, severity)
+	case "leef":
+		data = "-"
+		message = formatLEEFMessage(entry, action)
+	default:
+		data = fmt.Sprintf(`[query@%s id="%d" timestamp="%s" client="%s" domain="%s" type="%s" status="%s" action="%s"]`,
+			syslogEnterpriseNumber,
+			entry.ID,
+			entry.Timestamp.UTC().Format(time.RFC3339Nano),
+			syslogSDReplacer.Replace(entry.Client),
+			syslogSDReplacer.Replace(entry.Domain),
+			syslogSDReplacer.Replace(entry.Type),
+			syslogSDReplacer.Replace(entry.Status),
+			action,
+		)
+		message = fmt.Sprintf("%s %s query for %s from %s (%s)", action, entry.Type, entry.Domain, entry.Client, entry.Status)
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d query %s %s",
+		config.Syslog.facility*8+severity,
+		now.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		config.Syslog.Hostname,
+		config.Syslog.AppName,
+		os.Getpid(),
+		data,
+		message,
+	)
+}
+
+func formatCEFMessage(entry QueryLogEntry, action string, severity int) string {
+	var cefSeverity = "3"
+	var extension []string
+
+	if severity == syslogSeverityNotice {
+		cefSeverity = "5"
+	}
+
+	source := "shost"
+	if net.ParseIP(entry.Client) != nil {
+		source = "src"
+	}
+
+	extension = append(extension,
+		"rt="+strconv.FormatInt(entry.Timestamp.UnixNano()/1e+06, 10),
+		source+"="+cefExtensionReplacer.Replace(entry.Client),
+		"act="+action,
+		"reason="+cefExtensionReplacer.Replace(entry.Status),
+		"cs1Label=domain",
+		"cs1="+cefExtensionReplacer.Replace(entry.Domain),
+		"cs2Label=queryType",
+		"cs2="+cefExtensionReplacer.Replace(entry.Type),
+		"externalId="+strconv.FormatInt(entry.ID, 10),
+	)
+
+	return strings.Join([]string{
+		"CEF:0",
+		"Pi-hole",
+		cefHeaderReplacer.Replace(name),
+		cefHeaderReplacer.Replace(version),
+		cefHeaderReplacer.Replace(entry.Status),
+		"DNS query " + action,
+		cefSeverity,
+		strings.Join(extension, " "),
+	}, "|")
+}
+
+func formatLEEFMessage(entry QueryLogEntry, action string) string {
+	var attributes []string
+
+	source := "srcHostName"
+	if net.ParseIP(entry.Client) != nil {
+		source = "src"
+	}
+
+	attributes = append(attributes,
+		"devTime="+entry.Timestamp.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"devTimeFormat=yyyy-MM-dd'T'HH:mm:ss.SSSX",
+		source+"="+leefReplacer.Replace(entry.Client),
+		"cat="+action,
+		"reason="+leefReplacer.Replace(entry.Status),
+		"domain="+leefReplacer.Replace(entry.Domain),
+		"queryType="+leefReplacer.Replace(entry.Type),
+		"externalId="+strconv.FormatInt(entry.ID, 10),
+	)
+
+	return strings.Join([]string{
+		"LEEF:1.0",
+		"Pi-hole",
+		leefReplacer.Replace(name),
+		leefReplacer.Replace(version),
+		leefReplacer.Replace(entry.Status),
+		strings.Join(attributes, "\t"),
+	}, "|")
+}
+
+func connectSyslog() (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout: config.Syslog.timeout,
+	}
+
+	if config.Syslog.Protocol != "tls" {
+		return dialer.Dial(config.Syslog.Protocol, config.Syslog.address)
+	}
+
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: config.Syslog.InsecureSSL,
+	}
+
+	if config.Syslog.CAFile != "" {
+		cadata, err := ioutil.ReadFile(config.Syslog.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cacerts := x509.NewCertPool()
+		if !cacerts.AppendCertsFromPEM(cadata) {
+			return nil, fmt.Errorf("Can't append CA data to CA pool")
+		}
+
+		tlsCfg.RootCAs = cacerts
+	}
+
+	return tls.DialWithDialer(dialer, "tcp", config.Syslog.address, tlsCfg)
+}
+
+func writeSyslog(message string) error {
+	var err error
+
+	if syslogConnection == nil {
+		syslogConnection, err = connectSyslog()
+		if err != nil {
+			syslogConnection = nil
+			return err
+		}
+
+		log.WithFields(log.Fields{
+			"syslog_server": config.Syslog.address,
+			"protocol":      config.Syslog.Protocol,
+		}).Info(formatLogString("Connected to syslog server"))
+	}
+
+	err = syslogConnection.SetWriteDeadline(time.Now().Add(config.Syslog.timeout))
+	if err != nil {
+		return err
+	}
+
+	// UDP: one message per datagram, TCP and TLS: octet counting (RFC 6587, RFC 5425) or newline as delimiter
+	switch {
+	case config.Syslog.Protocol == "udp":
+		_, err = syslogConnection.Write([]byte(message))
+	case config.Syslog.Framing == "newline":
+		_, err = syslogConnection.Write([]byte(message + "\n"))
+	default:
+		_, err = syslogConnection.Write([]byte(strconv.Itoa(len(message)) + " " + message))
+	}
+
+	return err
+}
+
+func closeSyslog() {
+	if syslogConnection != nil {
+		syslogConnection.Close()
+		syslogConnection = nil
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func textfileWriter() {
+	log.WithFields(log.Fields{
+		"textfile": config.Textfile.Path,
+		"interval": config.Textfile.interval.String(),
+	}).Info(formatLogString("Starting output for the textfile collector"))
+
+	ticker := time.NewTicker(config.Textfile.interval)
+	for {
+		writeTextfile()
+		<-ticker.C
+	}
+}
+
+// writeTextfile - the old file is kept if the PiHole server is not reachable, staleness can be detected by node_textfile_mtime_seconds
+func writeTextfile() {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	err = writeFileAtomic(config.Textfile.Path, generatePrometheusData(rawsum, qtypes))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"textfile": config.Textfile.Path,
+			"error":    err.Error(),
+		}).Error(formatLogString("Can't write textfile"))
+	}
+}
+
+// writeFileAtomic - write data to a temporary file in the same directory and rename it, so readers never see a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	// the name of the temporary file doesn't end with .prom, it is ignored by the textfile collector
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	// temporary files are created with mode 0600, the node_exporter usually runs as a different user
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	// persist the rename
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
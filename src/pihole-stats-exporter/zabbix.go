@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// header of the Zabbix protocol, followed by the length of the data (4 byte) and a reserved field (4 byte), both little endian
+const zabbixHeader = "ZBXD\x01"
+const zabbixHeaderLength = 13
+
+// refuse to read responses larger than this
+const zabbixMaxResponseLength = 1024 * 1024
+
+func zabbixSender() {
+	log.WithFields(log.Fields{
+		"zabbix_server": config.Zabbix.address,
+		"host":          config.Zabbix.Host,
+		"key_prefix":    config.Zabbix.KeyPrefix,
+		"interval":      config.Zabbix.interval.String(),
+	}).Info(formatLogString("Starting Zabbix output"))
+
+	ticker := time.NewTicker(config.Zabbix.interval)
+	for {
+		sendZabbix()
+		<-ticker.C
+	}
+}
+
+func sendZabbix() {
+	rawsum, err := getPiHoleRawSummary()
+	if err != nil {
+		return
+	}
+
+	qtypes, err := getPiHoleQueryTypes()
+	if err != nil {
+		return
+	}
+
+	// the remaining values are still useful if the forward destinations are not available
+	fwd, _ := getPiHoleForwardDestinations()
+
+	request := ZabbixSenderRequest{
+		Request: "sender data",
+		Data:    generateZabbixData(rawsum, qtypes, fwd, time.Now().Unix()),
+		Clock:   time.Now().Unix(),
+	}
+
+	response, err := sendZabbixRequest(request)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"zabbix_server": config.Zabbix.address,
+			"error":         err.Error(),
+		}).Error(formatLogString("Can't send data to Zabbix"))
+
+		return
+	}
+
+	if response.Response != "success" {
+		log.WithFields(log.Fields{
+			"zabbix_server": config.Zabbix.address,
+			"response":      response.Response,
+			"info":          response.Info,
+		}).Error(formatLogString("Zabbix rejected the data"))
+
+		return
+	}
+
+	// values for unknown hosts or items (or items of the wrong type) are counted as failed
+	var processed, failed, total uint64
+	var spent float64
+	_, err = fmt.Sscanf(response.Info, "processed: %d; failed: %d; total: %d; seconds spent: %f", &processed, &failed, &total, &spent)
+	if err == nil && failed > 0 {
+		log.WithFields(log.Fields{
+			"zabbix_server": config.Zabbix.address,
+			"host":          config.Zabbix.Host,
+			"processed":     processed,
+			"failed":        failed,
+			"total":         total,
+		}).Warning(formatLogString("Zabbix didn't accept all values, check host name and trapper items"))
+	}
+}
+
+func generateZabbixData(rawsum PiHoleRawSummary, qtypes PiHoleQueryTypes, fwd PiHoleForwardDestinations, now int64) []ZabbixSenderItem {
+	var result []ZabbixSenderItem
+
+	blocking := "0"
+	if rawsum.Status == "enabled" {
+		blocking = "1"
+	}
+	result = append(result, ZabbixSenderItem{
+		Host:  config.Zabbix.Host,
+		Key:   config.Zabbix.KeyPrefix + ".blocking",
+		Value: blocking,
+		Clock: now,
+	})
+
+	for _, v := range getPiHoleValues(rawsum, qtypes) {
+		var key string
+
+		switch v.Group {
+		case "reply":
+			key = config.Zabbix.KeyPrefix + ".reply[" + zabbixKeyParameter(v.Name) + "]"
+		case "querytypes":
+			key = config.Zabbix.KeyPrefix + ".querytype[" + zabbixKeyParameter(v.Name) + "]"
+		default:
+			key = config.Zabbix.KeyPrefix + "." + v.Name
+		}
+
+		result = append(result, ZabbixSenderItem{
+			Host:  config.Zabbix.Host,
+			Key:   key,
+			Value: strconv.FormatFloat(v.Value, 'f', -1, 64),
+			Clock: now,
+		})
+	}
+
+	for _, upstream := range getZabbixUpstreams(fwd) {
+		result = append(result, ZabbixSenderItem{
+			Host:  config.Zabbix.Host,
+			Key:   config.Zabbix.KeyPrefix + ".upstream[" + zabbixKeyParameter(upstream["{#UPSTREAM}"]) + "]",
+			Value: strconv.FormatFloat(fwd.ForwardDestinations[upstream["{#UPSTREAM.KEY}"]], 'f', -1, 64),
+			Clock: now,
+		})
+	}
+
+	return result
+}
+
+// zabbixKeyParameter - quote parameters of item keys, embedded quotes must be escaped
+func zabbixKeyParameter(s string) string {
+	return "\"" + strings.Replace(s, "\"", "\\\"", -1) + "\""
+}
+
+// getZabbixUpstreams - upstream DNS servers, the pseudo destinations for blocked and cached queries are skipped
+func getZabbixUpstreams(fwd PiHoleForwardDestinations) []map[string]string {
+	var result = make([]map[string]string, 0)
+	var keys []string
+
+	for key := range fwd.ForwardDestinations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		// keys are reported as "name|address", the name may be identical to the address
+		nameAddr := strings.SplitN(key, "|", 2)
+		if len(nameAddr) != 2 {
+			nameAddr = append(nameAddr, nameAddr[0])
+		}
+
+		if nameAddr[1] == "blocked" || nameAddr[1] == "cached" {
+			continue
+		}
+
+		result = append(result, map[string]string{
+			"{#UPSTREAM}":      nameAddr[1],
+			"{#UPSTREAM.NAME}": nameAddr[0],
+			"{#UPSTREAM.KEY}":  key,
+		})
+	}
+
+	return result
+}
+
+// printZabbixLLD - print low-level discovery data of the requested type to stdout
+func printZabbixLLD(lldType string) error {
+	var lld = ZabbixLLD{
+		Data: make([]map[string]string, 0),
+	}
+	var rawsum PiHoleRawSummary
+	var qtypes PiHoleQueryTypes
+
+	switch lldType {
+	case "querytypes":
+		for _, v := range getPiHoleValues(rawsum, qtypes) {
+			if v.Group == "querytypes" {
+				lld.Data = append(lld.Data, map[string]string{"{#QTYPE}": v.Name})
+			}
+		}
+	case "replies":
+		for _, v := range getPiHoleValues(rawsum, qtypes) {
+			if v.Group == "reply" {
+				lld.Data = append(lld.Data, map[string]string{"{#REPLY}": v.Name})
+			}
+		}
+	case "upstreams":
+		fwd, err := getPiHoleForwardDestinations()
+		if err != nil {
+			return err
+		}
+
+		for _, upstream := range getZabbixUpstreams(fwd) {
+			delete(upstream, "{#UPSTREAM.KEY}")
+			lld.Data = append(lld.Data, upstream)
+		}
+	default:
+		return fmt.Errorf("Unsupported type for Zabbix low-level discovery, must be querytypes, replies or upstreams")
+	}
+
+	payload, err := json.Marshal(lld)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(append(payload, '\n'))
+	return err
+}
+
+func sendZabbixRequest(request ZabbixSenderRequest) (ZabbixSenderResponse, error) {
+	var response ZabbixSenderResponse
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+
+	conn, err := net.DialTimeout("tcp", config.Zabbix.address, config.Zabbix.timeout)
+	if err != nil {
+		return response, err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(config.Zabbix.timeout))
+	if err != nil {
+		return response, err
+	}
+
+	_, err = conn.Write(encodeZabbixPacket(payload))
+	if err != nil {
+		return response, err
+	}
+
+	header := make([]byte, zabbixHeaderLength)
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		return response, err
+	}
+
+	if string(header[0:4]) != zabbixHeader[0:4] {
+		return response, fmt.Errorf("Invalid header in Zabbix response")
+	}
+
+	// compressed responses are never sent because the request is not compressed
+	length := binary.LittleEndian.Uint32(header[5:9])
+	if length > zabbixMaxResponseLength {
+		return response, fmt.Errorf("Zabbix response is too large")
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(conn, data)
+	if err != nil {
+		return response, err
+	}
+
+	err = json.Unmarshal(data, &response)
+	return response, err
+}
+
+func encodeZabbixPacket(payload []byte) []byte {
+	var result = make([]byte, zabbixHeaderLength, zabbixHeaderLength+len(payload))
+
+	copy(result, zabbixHeader)
+	binary.LittleEndian.PutUint32(result[5:9], uint32(len(payload)))
+
+	return append(result, payload...)
+}